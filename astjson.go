@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// astJSONNode is the JSON form of a goldmark AST node, for tools which want to consume exactly what
+// rmd sees
+type astJSONNode struct {
+	Kind string `json:"kind"`
+	// node specific fields e.g. heading level or link destination
+	Properties map[string]any `json:"properties,omitempty"`
+	// attributes attached to the node e.g. via `{#id .class}` or auto heading IDs
+	Attributes map[string]any `json:"attributes,omitempty"`
+	// literal content of leaf nodes: text, code and raw html
+	Text     string         `json:"text,omitempty"`
+	Range    *astJSONRange  `json:"range,omitempty"`
+	Children []*astJSONNode `json:"children,omitempty"`
+}
+
+// astJSONRange is the source range covered by a node's content; end is exclusive. Nodes of included
+// files and snippets get the range of the include directive or code block they were expanded from.
+type astJSONRange struct {
+	Start astJSONPosition `json:"start"`
+	End   astJSONPosition `json:"end"`
+}
+
+// astJSONPosition is a position in the input file, front matter included; line and column are
+// 1-based and columns count bytes
+type astJSONPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+func renderASTJSON(w io.Writer, md goldmark.Markdown, doc *document) error {
+	root := md.Parser().Parse(text.NewReader(doc.source))
+	c := &astJSONConverter{source: doc.source, doc: doc}
+	for i, b := range doc.input {
+		if b == '\n' {
+			c.lineStarts = append(c.lineStarts, i+1)
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.convert(root))
+}
+
+type astJSONConverter struct {
+	source []byte
+	// positions are reported in terms of the doc's input rather than source, which lacks the front
+	// matter and has includes and snippets expanded
+	doc *document
+	// offsets at which lines 2, 3, ... of the input start
+	lineStarts []int
+}
+
+func (c *astJSONConverter) convert(n ast.Node) *astJSONNode {
+	out := &astJSONNode{
+		Kind:       n.Kind().String(),
+		Properties: astProperties(n, c.source),
+		Text:       c.text(n),
+	}
+	if attrs := n.Attributes(); len(attrs) > 0 {
+		out.Attributes = make(map[string]any, len(attrs))
+		for _, a := range attrs {
+			if v, ok := a.Value.([]byte); ok {
+				out.Attributes[string(a.Name)] = string(v)
+			} else {
+				out.Attributes[string(a.Name)] = a.Value
+			}
+		}
+	}
+	if start, stop, ok := c.span(n); ok {
+		out.Range = &astJSONRange{
+			Start: c.position(c.doc.inputOffset(start, false)),
+			End:   c.position(c.doc.inputOffset(stop, true)),
+		}
+	}
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		out.Children = append(out.Children, c.convert(child))
+	}
+	return out
+}
+
+func astProperties(n ast.Node, source []byte) map[string]any {
+	switch n := n.(type) {
+	case *ast.Heading:
+		return map[string]any{"level": n.Level}
+	case *ast.List:
+		p := map[string]any{"ordered": n.IsOrdered(), "marker": string(n.Marker), "tight": n.IsTight}
+		if n.IsOrdered() {
+			p["start"] = n.Start
+		}
+		return p
+	case *ast.FencedCodeBlock:
+		if n.Info == nil {
+			return nil
+		}
+		return map[string]any{"language": string(n.Language(source)), "info": string(n.Info.Segment.Value(source))}
+	case *ast.HTMLBlock:
+		return map[string]any{"htmlBlockType": int(n.HTMLBlockType)}
+	case *ast.Emphasis:
+		return map[string]any{"level": n.Level}
+	case *ast.Link:
+		return linkProperties(n.Destination, n.Title)
+	case *ast.Image:
+		return linkProperties(n.Destination, n.Title)
+	case *ast.AutoLink:
+		typ := "url"
+		if n.AutoLinkType == ast.AutoLinkEmail {
+			typ = "email"
+		}
+		return map[string]any{"url": string(n.URL(source)), "type": typ}
+	case *ast.Text:
+		p := map[string]any{}
+		if n.SoftLineBreak() {
+			p["softLineBreak"] = true
+		}
+		if n.HardLineBreak() {
+			p["hardLineBreak"] = true
+		}
+		if len(p) == 0 {
+			return nil
+		}
+		return p
+	case *wikiLink:
+		p := map[string]any{"target": n.target, "href": n.href, "resolved": n.href != ""}
+		if n.anchor != "" {
+			p["anchor"] = n.anchor
+		}
+		return p
+	case *figureBlock:
+		if len(n.caption) == 0 {
+			return nil
+		}
+		return map[string]any{"caption": string(n.caption)}
+	case *imageAttrs:
+		return map[string]any{"raw": n.raw}
+	case *csvTable:
+		return map[string]any{"rows": n.rows, "header": n.header, "omittedRows": n.omitted}
+	case *containerBlock:
+		return map[string]any{"name": n.name, "title": n.title}
+	case *mathInline:
+		return map[string]any{"display": n.display}
+	case *east.TaskCheckBox:
+		return map[string]any{"checked": n.IsChecked}
+	case *east.Table:
+		aligns := make([]string, len(n.Alignments))
+		for i, a := range n.Alignments {
+			aligns[i] = a.String()
+		}
+		return map[string]any{"alignments": aligns}
+	case *east.TableCell:
+		return map[string]any{"alignment": n.Alignment.String()}
+	}
+	return nil
+}
+
+func linkProperties(dest, title []byte) map[string]any {
+	p := map[string]any{"destination": string(dest)}
+	if len(title) > 0 {
+		p["title"] = string(title)
+	}
+	return p
+}
+
+func (c *astJSONConverter) text(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Text:
+		v := n.Segment.Value(c.source)
+		if n.IsRaw() {
+			return string(v)
+		}
+		return string(util.ResolveEntityNames(util.ResolveNumericReferences(util.UnescapePunctuations(v))))
+	case *ast.String:
+		return string(n.Value)
+	case *ast.RawHTML:
+		var b strings.Builder
+		for i := 0; i < n.Segments.Len(); i++ {
+			seg := n.Segments.At(i)
+			b.Write(seg.Value(c.source))
+		}
+		return b.String()
+	case *ast.CodeBlock, *ast.FencedCodeBlock, *ast.HTMLBlock:
+		var b strings.Builder
+		for i := 0; i < n.Lines().Len(); i++ {
+			seg := n.Lines().At(i)
+			b.Write(seg.Value(c.source))
+		}
+		return b.String()
+	}
+	return ""
+}
+
+// span returns the source offsets covered by the node's own content and its descendants
+func (c *astJSONConverter) span(n ast.Node) (start, stop int, ok bool) {
+	add := func(s, e int) {
+		if !ok || s < start {
+			start = s
+		}
+		if !ok || e > stop {
+			stop = e
+		}
+		ok = true
+	}
+	switch n := n.(type) {
+	case *ast.Text:
+		add(n.Segment.Start, n.Segment.Stop)
+	case *ast.RawHTML:
+		for i := 0; i < n.Segments.Len(); i++ {
+			add(n.Segments.At(i).Start, n.Segments.At(i).Stop)
+		}
+	case *ast.AutoLink:
+		if off := sourceOffset(c.source, n.Label(c.source)); off >= 0 {
+			add(off, off+len(n.Label(c.source)))
+		}
+	}
+	if n.Type() == ast.TypeBlock {
+		for i := 0; i < n.Lines().Len(); i++ {
+			add(n.Lines().At(i).Start, n.Lines().At(i).Stop)
+		}
+	}
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		if s, e, cok := c.span(child); cok {
+			add(s, e)
+		}
+	}
+	return start, stop, ok
+}
+
+func (c *astJSONConverter) position(offset int) astJSONPosition {
+	// number of lines starting at or before offset
+	i := sort.SearchInts(c.lineStarts, offset+1)
+	lineStart := 0
+	if i > 0 {
+		lineStart = c.lineStarts[i-1]
+	}
+	return astJSONPosition{Line: i + 1, Column: offset - lineStart + 1, Offset: offset}
+}
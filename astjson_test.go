@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestASTJSONPositions(t *testing.T) {
+	tests := []struct {
+		name, md string
+		// kind and text of the node checked, and its expected range as line:column pairs
+		kind, text string
+		start, end [2]int
+	}{
+		{
+			name:  "plain",
+			md:    "# Head\n\nsome *text*\n",
+			kind:  "Text",
+			text:  "text",
+			start: [2]int{3, 7},
+			end:   [2]int{3, 11},
+		},
+		{
+			name:  "front matter",
+			md:    "---\ntitle: T\ntags: [a]\n---\n# Head\n\nsome *text*\n",
+			kind:  "Text",
+			text:  "text",
+			start: [2]int{7, 7},
+			end:   [2]int{7, 11},
+		},
+		{
+			name:  "after an include",
+			md:    "---\ntitle: T\n---\n<!-- include: inc.md -->\n\nsome *text*\n",
+			kind:  "Text",
+			text:  "text",
+			start: [2]int{6, 7},
+			end:   [2]int{6, 11},
+		},
+		{
+			name:  "within an include",
+			md:    "---\ntitle: T\n---\nintro\n\n<!-- include: inc.md -->\n",
+			kind:  "Text",
+			text:  "included",
+			start: [2]int{6, 1},
+			end:   [2]int{6, 25},
+		},
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "inc.md"), []byte("included\n\nlines\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "doc.md")
+			if err := os.WriteFile(path, []byte(tt.md), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			doc, err := loadDocument(path, markdownOptions{}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := renderASTJSON(&out, doc.md, doc); err != nil {
+				t.Fatal(err)
+			}
+			var root astJSONNode
+			if err := json.Unmarshal(out.Bytes(), &root); err != nil {
+				t.Fatal(err)
+			}
+			n := findASTJSONNode(&root, tt.kind, tt.text)
+			if n == nil || n.Range == nil {
+				t.Fatalf("no %s node %q w/ range in:\n%s", tt.kind, tt.text, out.String())
+			}
+			start := [2]int{n.Range.Start.Line, n.Range.Start.Column}
+			end := [2]int{n.Range.End.Line, n.Range.End.Column}
+			if start != tt.start || end != tt.end {
+				t.Errorf("range = %v-%v, want %v-%v", start, end, tt.start, tt.end)
+			}
+			// offsets point at the same place of the input as lines and columns do
+			if got := string(tt.md[n.Range.Start.Offset]); tt.name != "within an include" && got != tt.text[:1] {
+				t.Errorf("input at start offset %d is %q", n.Range.Start.Offset, got)
+			}
+		})
+	}
+}
+
+func findASTJSONNode(n *astJSONNode, kind, text string) *astJSONNode {
+	if n.Kind == kind && n.Text == text {
+		return n
+	}
+	for _, c := range n.Children {
+		if found := findASTJSONNode(c, kind, text); found != nil {
+			return found
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// autoref is a rule turning references in text, e.g. `#123` or `PROJ-456`, into links
+type autoref struct {
+	re *regexp.Regexp
+	// URL template w/ $1 or ${name} referring to submatches
+	url string
+}
+
+// autorefSyntax is the goldmark extension which links references in text after parsing; code, links
+// and raw html are left alone
+type autorefSyntax struct {
+	rules []autoref
+}
+
+// newAutorefSyntax returns the extension of the autolink rules of config, which have been validated
+// when loading it
+func newAutorefSyntax(cfg *config) *autorefSyntax {
+	s := &autorefSyntax{}
+	for _, r := range cfg.Autolinks {
+		s.rules = append(s.rules, autoref{re: regexp.MustCompile(r.Pattern), url: r.URL})
+	}
+	return s
+}
+
+func (s *autorefSyntax) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(s, 500)))
+}
+
+func (s *autorefSyntax) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	var texts []*ast.Text
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.CodeSpan, *ast.Link, *ast.AutoLink, *ast.Image, *ast.RawHTML, *wikiLink:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			if !n.IsRaw() {
+				texts = append(texts, n)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	// nodes get replaced after walking so as not to disturb it
+	for _, t := range texts {
+		s.link(t, source)
+	}
+}
+
+type autorefMatch struct {
+	start, stop int
+	url         string
+}
+
+// link splits text node t around references, which become links
+func (s *autorefSyntax) link(t *ast.Text, source []byte) {
+	value := t.Segment.Value(source)
+	var matches []autorefMatch
+	for _, r := range s.rules {
+		for _, m := range r.re.FindAllSubmatchIndex(value, -1) {
+			if m[0] == m[1] || !wordBoundary(value, m[0], m[1]) {
+				continue
+			}
+			url := r.re.Expand(nil, []byte(r.url), value, m)
+			matches = append(matches, autorefMatch{start: m[0], stop: m[1], url: string(url)})
+		}
+	}
+	if len(matches) == 0 {
+		return
+	}
+	// earlier matches win over overlapping later ones, and so do earlier rules on ties
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	parent := t.Parent()
+	var prev ast.Node = t
+	last := 0
+	insert := func(n ast.Node) {
+		parent.InsertAfter(parent, prev, n)
+		prev = n
+	}
+	base := t.Segment.Start
+	for _, m := range matches {
+		if m.start < last {
+			continue
+		}
+		if m.start > last {
+			insert(ast.NewTextSegment(text.NewSegment(base+last, base+m.start)))
+		}
+		link := ast.NewLink()
+		link.Destination = []byte(m.url)
+		link.AppendChild(link, ast.NewTextSegment(text.NewSegment(base+m.start, base+m.stop)))
+		insert(link)
+		last = m.stop
+	}
+	// the remaining text inherits line breaks of the original node
+	rest := ast.NewTextSegment(text.NewSegment(base+last, t.Segment.Stop))
+	rest.SetSoftLineBreak(t.SoftLineBreak())
+	rest.SetHardLineBreak(t.HardLineBreak())
+	if last < len(value) || t.SoftLineBreak() || t.HardLineBreak() {
+		insert(rest)
+	}
+	parent.RemoveChild(parent, t)
+}
+
+// wordBoundary tells whether value[start:stop] stands apart from adjacent words, so that e.g.
+// `@alice` is not taken from `bob@alice.com`
+func wordBoundary(value []byte, start, stop int) bool {
+	isWord := func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+	if before, _ := utf8.DecodeLastRune(value[:start]); start > 0 && isWord(before) {
+		return false
+	}
+	after, _ := utf8.DecodeRune(value[stop:])
+	last, _ := utf8.DecodeLastRune(value[:stop])
+	return stop == len(value) || !isWord(after) || !isWord(last)
+}
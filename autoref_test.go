@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAutoref(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, configFileName)
+	rules := `{"autolinks": [
+		{"pattern": "#(\\d+)", "url": "https://git.example/issues/$1"},
+		{"pattern": "(?P<key>[A-Z]+-\\d+)", "url": "https://jira.example/browse/${key}"},
+		{"pattern": "@(\\w+)", "url": "https://git.example/$1"},
+		{"pattern": "[0-9a-f]{7,40}", "url": "https://git.example/commit/$0"}
+	]}`
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, md, want string
+	}{
+		{
+			name: "references",
+			md:   "Fixes #12 and PROJ-3, thanks @alice in abc1234.",
+			want: `<p>Fixes <a href="https://git.example/issues/12">#12</a> and <a href="https://jira.example/browse/PROJ-3">PROJ-3</a>, thanks <a href="https://git.example/alice">@alice</a> in <a href="https://git.example/commit/abc1234">abc1234</a>.</p>`,
+		},
+		{
+			name: "word boundaries",
+			md:   "bob@alice a#12 XPROJ-3x abc",
+			want: `<p>bob@alice a#12 XPROJ-3x abc</p>`,
+		},
+		{
+			name: "code, links and html left alone",
+			md:   "`#1` [#2](x) <https://a.example/#3> <span title=\"#4\">#5</span>",
+			want: `<p><code>#1</code> <a href="x">#2</a> <a href="https://a.example/#3">https://a.example/#3</a> <span title="#4"><a href="https://git.example/issues/5">#5</a></span></p>`,
+		},
+		{
+			name: "line breaks kept",
+			md:   "see #1\nand *#2*",
+			want: "<p>see <a href=\"https://git.example/issues/1\">#1</a>\nand <em><a href=\"https://git.example/issues/2\">#2</a></em></p>",
+		},
+	}
+	md, err := markdownFor(markdownOptions{profile: "gfm-doc", html: htmlUnsafe}, nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := md.Convert([]byte(tt.md), &out); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(out.String()); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutorefInvalidPattern(t *testing.T) {
+	path := filepath.Join(t.TempDir(), configFileName)
+	if err := os.WriteFile(path, []byte(`{"autolinks": [{"pattern": "(", "url": "x"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(path, ""); err == nil || !strings.Contains(err.Error(), "invalid autolink pattern") {
+		t.Errorf("got %v, want an invalid autolink pattern error", err)
+	}
+}
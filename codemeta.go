@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// fenceInfo is the info string of a fenced code block taken apart, e.g.
+//
+//	```go title="main.go" {3,7-9} linenos
+type fenceInfo struct {
+	language string
+	title    string
+	// line ranges to highlight as written inside the braces, e.g. `3,7-9`
+	highlight string
+	linenos   bool
+	// number of the first line when numbering lines
+	start int
+	// key=value attributes other than the ones above
+	attrs map[string]string
+}
+
+// highlightRangesRe matches the line ranges of braces as in `{3,7-9}`
+var highlightRangesRe = regexp.MustCompile(`^[\d,\s-]+$`)
+
+// parseFenceInfo takes apart info strings like `go title="main.go" {3,7-9} linenos`; braces not
+// holding line ranges are attribute lists as Pandoc and MkDocs write them, e.g.
+// `{.go title="main.go" hl_lines="2 4"}`
+func parseFenceInfo(info string) fenceInfo {
+	fi := fenceInfo{start: 1, attrs: map[string]string{}}
+	fields := splitQuoted(info)
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if strings.HasPrefix(f, "{") {
+			// ranges may be written w/ spaces e.g. `{3, 7-9}`, as may attribute lists
+			for !strings.HasSuffix(f, "}") && i+1 < len(fields) {
+				i++
+				f += " " + fields[i]
+			}
+			inner := strings.TrimSuffix(strings.TrimPrefix(f, "{"), "}")
+			if highlightRangesRe.MatchString(inner) {
+				fi.highlight = inner
+				continue
+			}
+			for _, a := range splitQuoted(inner) {
+				switch {
+				case strings.HasPrefix(a, ".") && fi.language == "":
+					fi.language = a[1:]
+				case strings.HasPrefix(a, "#"):
+					fi.attrs["id"] = a[1:]
+				default:
+					fi.set(a)
+				}
+			}
+			continue
+		}
+		if _, _, isAttr := strings.Cut(f, "="); !isAttr && f != "linenos" && i == 0 {
+			fi.language = f
+			continue
+		}
+		fi.set(f)
+	}
+	return fi
+}
+
+// set applies a `key=value` or `linenos` field of an info string
+func (fi *fenceInfo) set(f string) {
+	k, v, isAttr := strings.Cut(f, "=")
+	switch {
+	case f == "linenos":
+		fi.linenos = true
+	case isAttr && k == "title":
+		fi.title = unquote(v)
+	case isAttr && k == "hl_lines":
+		// MkDocs separates ranges by spaces
+		fi.highlight = strings.Join(strings.Fields(unquote(v)), ",")
+	case isAttr && k == "linenostart":
+		if n, err := strconv.Atoi(unquote(v)); err == nil {
+			fi.linenos, fi.start = true, n
+		}
+	case isAttr:
+		fi.attrs[k] = unquote(v)
+	}
+}
+
+// highlighted returns which of n lines (0-based) to highlight; ranges out of bounds are ignored
+func (fi fenceInfo) highlighted(n int) map[int]bool {
+	lines := map[int]bool{}
+	for _, r := range strings.Split(fi.highlight, ",") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		from, to, err := parseLineRange(r, n)
+		if err != nil {
+			continue
+		}
+		for i := from; i <= to; i++ {
+			lines[i-1] = true
+		}
+	}
+	return lines
+}
+
+// codeBlockCSS styles code blocks w/ metadata on top of the GitHub style
+const codeBlockCSS = `
+.markdown-body .code-block {
+  margin-bottom: 16px;
+  border-radius: 6px;
+  background-color: #f6f8fa;
+}
+
+.markdown-body .code-block-title {
+  padding: 8px 16px;
+  font-family: ui-monospace, SFMono-Regular, SF Mono, Menlo, Consolas, Liberation Mono, monospace;
+  font-size: 85%;
+  color: #59636e;
+  border-bottom: 1px solid #d1d9e0;
+}
+
+.markdown-body .code-block pre {
+  border-radius: 0 0 6px 6px;
+}
+
+.markdown-body .code-block .line-number {
+  display: inline-block;
+  padding-right: 12px;
+  margin-right: 12px;
+  color: #59636e;
+  text-align: right;
+  border-right: 1px solid #d1d9e0;
+  user-select: none;
+}
+
+.markdown-body .code-block mark.line {
+  display: inline-block;
+  min-width: 100%;
+  color: inherit;
+  background-color: #fff8c5;
+}
+`
+
+// codeMetaSyntax is the goldmark extension rendering titles, line numbers and highlighted lines of
+// fenced code blocks per their info strings
+type codeMetaSyntax struct{}
+
+func (codeMetaSyntax) Extend(m goldmark.Markdown) {
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(codeMetaSyntax{}, 100)))
+}
+
+func (codeMetaSyntax) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, renderFencedCode)
+}
+
+func renderFencedCode(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+	var fi fenceInfo
+	if n.Info != nil {
+		fi = parseFenceInfo(string(n.Info.Segment.Value(source)))
+	}
+	lines := make([][]byte, n.Lines().Len())
+	for i := range lines {
+		seg := n.Lines().At(i)
+		lines[i] = seg.Value(source)
+	}
+	plain := fi.title == "" && !fi.linenos && fi.highlight == ""
+
+	if !plain {
+		_, _ = w.WriteString(`<div class="highlight code-block">` + "\n")
+		if fi.title != "" {
+			_, _ = w.WriteString(`<div class="code-block-title">` + html.EscapeString(fi.title) + "</div>\n")
+		}
+	}
+	_, _ = w.WriteString("<pre><code")
+	if fi.language != "" {
+		_, _ = w.WriteString(` class="language-` + html.EscapeString(fi.language) + `"`)
+	}
+	_, _ = w.WriteString(">")
+	if plain {
+		for _, line := range lines {
+			_, _ = w.Write(util.EscapeHTML(line))
+		}
+		_, _ = w.WriteString("</code></pre>\n")
+		return ast.WalkContinue, nil
+	}
+
+	// every line gets its own element so that it can be marked; numbers are text rather than CSS
+	// counters, which keeps them readable w/o any style
+	marked := fi.highlighted(len(lines))
+	width := len(strconv.Itoa(fi.start + len(lines) - 1))
+	for i, line := range lines {
+		tag := "span"
+		if marked[i] {
+			tag = "mark"
+		}
+		_, _ = w.WriteString("<" + tag + ` class="line">`)
+		content := strings.TrimRight(string(line), "\r\n")
+		if fi.linenos {
+			_, _ = fmt.Fprintf(w, `<span class="line-number">%*d</span>`, width, fi.start+i)
+			if content != "" {
+				_, _ = w.WriteString(" ")
+			}
+		}
+		_, _ = w.Write(util.EscapeHTML([]byte(content)))
+		_, _ = w.WriteString("</" + tag + ">\n")
+	}
+	_, _ = w.WriteString("</code></pre>\n</div>\n")
+	return ast.WalkContinue, nil
+}
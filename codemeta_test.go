@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFenceInfo(t *testing.T) {
+	tests := []struct {
+		info string
+		want fenceInfo
+	}{
+		{
+			info: "go",
+			want: fenceInfo{language: "go", start: 1, attrs: map[string]string{}},
+		},
+		{
+			info: `go title="main.go" {3,7-9} linenos`,
+			want: fenceInfo{language: "go", title: "main.go", highlight: "3,7-9", linenos: true, start: 1, attrs: map[string]string{}},
+		},
+		{
+			info: "go {3, 7-9} linenostart=5",
+			want: fenceInfo{language: "go", highlight: "3, 7-9", linenos: true, start: 5, attrs: map[string]string{}},
+		},
+		{
+			info: `go {title="main.go" hl_lines="2 4-5"}`,
+			want: fenceInfo{language: "go", title: "main.go", highlight: "2,4-5", start: 1, attrs: map[string]string{}},
+		},
+		{
+			info: `{.py #ex linenos data-x="a b"}`,
+			want: fenceInfo{language: "py", linenos: true, start: 1, attrs: map[string]string{"id": "ex", "data-x": "a b"}},
+		},
+		{
+			info: `go {title="a}b"}`,
+			want: fenceInfo{language: "go", title: "a}b", start: 1, attrs: map[string]string{}},
+		},
+		{
+			info: "sh {not ranges}",
+			want: fenceInfo{language: "sh", start: 1, attrs: map[string]string{}},
+		},
+		{
+			info: "linenos",
+			want: fenceInfo{linenos: true, start: 1, attrs: map[string]string{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.info, func(t *testing.T) {
+			if got := parseFenceInfo(tt.info); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeMeta(t *testing.T) {
+	md, err := markdownFor(markdownOptions{profile: "rmd-extended"}, nil, &config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	src := "```go {title=\"main.go\" hl_lines=\"2\"}\na\nb\n```\n"
+	if err := md.Convert([]byte(src), &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`<div class="code-block-title">main.go</div>`, `<mark class="line">b</mark>`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// name of the per project config file, looked up from the input file's directory upwards
+const configFileName = ".rmd.json"
+
+// config holds per project settings
+type config struct {
+	// name of the Markdown dialect profile docs are rendered w/
+	Profile string `json:"profile"`
+	// adjustments to the extensions of the profile e.g. "+deflist" or "-strikethrough"
+	Extensions []string `json:"extensions"`
+	Linkify    struct {
+		// URL schemes bare links are recognized w/, e.g. "https:"
+		Protocols []string `json:"protocols"`
+	} `json:"linkify"`
+	HTML struct {
+		// how raw html gets rendered: omit, unsafe or sanitize
+		Mode string `json:"mode"`
+		// tags -> attributes allowed on top of the default allowlist in sanitize mode
+		Allow map[string][]string `json:"allow"`
+		// tags removed from the allowlist
+		Deny []string `json:"deny"`
+	} `json:"html"`
+	Wiki struct {
+		// directory wiki links resolve within, relative to the config file; default the doc's directory
+		Root string `json:"root"`
+		// extension of link targets in place of the Markdown one, e.g. ".html"
+		Ext string `json:"ext"`
+	} `json:"wiki"`
+	// output format -> template file overriding the built-in one, e.g. "latex": "tex/paper.tex"
+	Templates map[string]string `json:"templates"`
+	// rules linking references like `#123` or `PROJ-456` in text
+	Autolinks []struct {
+		// regular expression of references
+		Pattern string `json:"pattern"`
+		// URL template w/ $1 or ${name} referring to submatches of the pattern
+		URL string `json:"url"`
+	} `json:"autolinks"`
+
+	// directory of the config file, which relative paths in config resolve against
+	dir string
+}
+
+// loadConfig reads config from path; w/ an empty path the nearest config file above dir is used
+// if any, otherwise a zero config is returned
+func loadConfig(path, dir string) (*config, error) {
+	if path == "" {
+		path = findConfig(dir)
+		if path == "" {
+			return &config{}, nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+	c := config{dir: filepath.Dir(path)}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	for _, r := range c.Autolinks {
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return nil, fmt.Errorf("error parsing config file %s: invalid autolink pattern %q: %w", path, r.Pattern, err)
+		}
+	}
+	return &c, nil
+}
+
+// resolveTemplate picks the template file of given output format by precedence: flag then config;
+// empty means the built-in one
+func resolveTemplate(flagValue string, cfg *config, format string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if t := cfg.Templates[format]; t != "" {
+		return filepath.Join(cfg.dir, t)
+	}
+	return ""
+}
+
+func findConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		p := filepath.Join(dir, configFileName)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
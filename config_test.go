@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "docs", "guide")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, configFileName), []byte(`{"profile": "gfm-doc"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dir, "other.json")
+	if err := os.WriteFile(other, []byte(`{"profile": "commonmark"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"profile": `), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, path, dir  string
+		profile, dirWant string
+		wantErr          bool
+	}{
+		{name: "found upwards", dir: sub, profile: "gfm-doc", dirWant: dir},
+		{name: "in the dir", dir: dir, profile: "gfm-doc", dirWant: dir},
+		{name: "path over lookup", path: other, dir: sub, profile: "commonmark", dirWant: dir},
+		{name: "none", dir: filepath.Dir(dir)},
+		{name: "missing file", path: filepath.Join(dir, "missing.json"), wantErr: true},
+		{name: "invalid json", path: bad, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadConfig(tt.path, tt.dir)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Profile != tt.profile || cfg.dir != tt.dirWant {
+				t.Errorf("got profile %q of %q, want %q of %q", cfg.Profile, cfg.dir, tt.profile, tt.dirWant)
+			}
+		})
+	}
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Confluence panel macros of alert containers
+var confluencePanels = map[string]string{
+	"note":      "info",
+	"tip":       "tip",
+	"important": "info",
+	"warning":   "note",
+	"caution":   "warning",
+}
+
+// renderConfluence converts the doc to the XHTML based storage format of Confluence pages, e.g. to
+// publish by the REST API. Code blocks become code macros, alerts panels and a TOC macro leads the
+// page unless front matter says `toc: false`. Local images refer to page attachments by file name.
+func renderConfluence(w io.Writer, md goldmark.Markdown, doc *document) error {
+	root := md.Parser().Parse(text.NewReader(doc.source))
+	cw := &confluenceWriter{source: doc.source, out: &strings.Builder{}, footnotes: map[int]*east.Footnote{}, noteNums: map[int]int{}}
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if fn, ok := n.(*east.Footnote); ok && entering {
+			cw.footnotes[fn.Index] = fn
+		}
+		return ast.WalkContinue, nil
+	})
+	// the page title shows above the page, so an H1 taken for title is left out
+	if doc.meta["title"] == "" {
+		for c := root.FirstChild(); c != nil; c = c.NextSibling() {
+			if h, ok := c.(*ast.Heading); ok && h.Level == 1 {
+				cw.title = h
+				break
+			}
+		}
+	}
+	if doc.meta["toc"] != "false" {
+		cw.out.WriteString(`<ac:structured-macro ac:name="toc"/>` + "\n")
+	}
+	cw.blocks(root)
+	if len(cw.notes) > 0 {
+		cw.out.WriteString("<hr/>\n<ol>\n")
+		for i, note := range cw.notes {
+			fmt.Fprintf(cw.out, "<li>%s%s</li>\n", confluenceAnchor("fn-"+strconv.Itoa(i+1)), note)
+		}
+		cw.out.WriteString("</ol>\n")
+	}
+	_, err := io.WriteString(w, cw.out.String())
+	return err
+}
+
+// confluenceWriter converts a goldmark AST to Confluence storage format
+type confluenceWriter struct {
+	source []byte
+	out    *strings.Builder
+	// H1 taken for the page title, if any
+	title *ast.Heading
+	// footnote definitions by index, footnotes referenced so far in order, converted, and their
+	// numbers by index
+	footnotes map[int]*east.Footnote
+	notes     []string
+	noteNums  map[int]int
+}
+
+func (w *confluenceWriter) blocks(parent ast.Node) {
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		w.block(c)
+	}
+}
+
+func (w *confluenceWriter) block(n ast.Node) {
+	out := w.out
+	switch n := n.(type) {
+	case *ast.Heading:
+		if n == w.title {
+			return
+		}
+		fmt.Fprintf(out, "<h%d>", n.Level)
+		// headings are anchored by their IDs, for links within the doc
+		if id, ok := n.AttributeString("id"); ok {
+			out.WriteString(confluenceAnchor(string(id.([]byte))))
+		}
+		w.inlines(n)
+		fmt.Fprintf(out, "</h%d>\n", n.Level)
+	case *ast.Paragraph, *ast.TextBlock:
+		out.WriteString("<p>")
+		w.inlines(n)
+		out.WriteString("</p>\n")
+	case *ast.ThematicBreak:
+		out.WriteString("<hr/>\n")
+	case *ast.CodeBlock:
+		w.code(n, fenceInfo{})
+	case *ast.FencedCodeBlock:
+		var fi fenceInfo
+		if n.Info != nil {
+			fi = parseFenceInfo(string(n.Info.Segment.Value(w.source)))
+		}
+		w.code(n, fi)
+	case *mathBlock:
+		w.code(n, fenceInfo{title: "Math"})
+	case *ast.Blockquote:
+		if alert, marker := blockquoteAlert(n, w.source); alert != "" {
+			w.panel(confluencePanels[alert], strings.ToUpper(alert[:1])+alert[1:], func() {
+				// the 1st paragraph w/o the marker line
+				if p := n.FirstChild(); p.ChildCount() > marker {
+					out.WriteString("<p>")
+					for c, i := p.FirstChild(), 0; c != nil; c, i = c.NextSibling(), i+1 {
+						if i >= marker {
+							w.inline(c)
+						}
+					}
+					out.WriteString("</p>\n")
+				}
+				for c := n.FirstChild().NextSibling(); c != nil; c = c.NextSibling() {
+					w.block(c)
+				}
+			})
+			return
+		}
+		out.WriteString("<blockquote>\n")
+		w.blocks(n)
+		out.WriteString("</blockquote>\n")
+	case *ast.List:
+		w.list(n)
+	case *east.DefinitionList:
+		// storage format has no definition lists; terms are bold, definitions indented
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			if _, ok := c.(*east.DefinitionTerm); ok {
+				out.WriteString("<p><strong>")
+				w.inlines(c)
+				out.WriteString("</strong></p>\n")
+				continue
+			}
+			out.WriteString(`<div style="margin-left: 30.0px;">` + "\n")
+			w.blocks(c)
+			out.WriteString("</div>\n")
+		}
+	case *east.Table:
+		out.WriteString("<table>\n<tbody>\n")
+		for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+			out.WriteString("<tr>")
+			tag := "td"
+			if _, ok := row.(*east.TableHeader); ok {
+				tag = "th"
+			}
+			for i, cell := 0, row.FirstChild(); cell != nil; i, cell = i+1, cell.NextSibling() {
+				out.WriteString("<" + tag + confluenceAlign(n.Alignments, i) + ">")
+				w.inlines(cell)
+				out.WriteString("</" + tag + ">")
+			}
+			out.WriteString("</tr>\n")
+		}
+		out.WriteString("</tbody>\n</table>\n")
+	case *csvTable:
+		out.WriteString("<table>\n<tbody>\n")
+		columns := 0
+		for r, row := range n.rows {
+			columns = max(columns, len(row))
+			tag := "td"
+			if r == 0 && n.header {
+				tag = "th"
+			}
+			out.WriteString("<tr>")
+			for _, cell := range row {
+				out.WriteString("<" + tag + ">" + xmlEscape(cell) + "</" + tag + ">")
+			}
+			out.WriteString("</tr>\n")
+		}
+		if n.omitted > 0 {
+			more := fmt.Sprintf("%d more rows", n.omitted)
+			if n.omitted == 1 {
+				more = "1 more row"
+			}
+			fmt.Fprintf(out, "<tr><td colspan=\"%d\"><em>%s</em></td></tr>\n", max(columns, 1), more)
+		}
+		out.WriteString("</tbody>\n</table>\n")
+	case *containerBlock:
+		w.container(n)
+	case *figureBlock:
+		out.WriteString("<p>")
+		w.inlines(n)
+		out.WriteString("</p>\n")
+		if len(n.caption) > 0 {
+			out.WriteString("<p><em>" + xmlEscape(string(n.caption)) + "</em></p>\n")
+		}
+	case *ast.HTMLBlock, *east.FootnoteList:
+		// raw html would likely not be valid storage format; footnotes go to the end
+	default:
+		w.blocks(n)
+	}
+}
+
+// container converts alerts to panels, details to expand macros and other containers to titled panels
+func (w *confluenceWriter) container(n *containerBlock) {
+	title := n.title
+	if title == "" {
+		title = strings.ToUpper(n.name[:1]) + n.name[1:]
+	}
+	macro := "panel"
+	if alert, ok := alertContainers[n.name]; ok {
+		macro = confluencePanels[alert]
+	} else if n.name == "details" {
+		macro = "expand"
+	}
+	w.panel(macro, title, func() { w.blocks(n) })
+}
+
+// panel writes a macro of given name holding rich text, e.g. an info panel
+func (w *confluenceWriter) panel(macro, title string, body func()) {
+	fmt.Fprintf(w.out, `<ac:structured-macro ac:name="%s"><ac:parameter ac:name="title">%s</ac:parameter><ac:rich-text-body>`+"\n", macro, xmlEscape(title))
+	body()
+	w.out.WriteString("</ac:rich-text-body></ac:structured-macro>\n")
+}
+
+// code converts a code block to a code macro
+func (w *confluenceWriter) code(n ast.Node, fi fenceInfo) {
+	out := w.out
+	out.WriteString(`<ac:structured-macro ac:name="code">`)
+	param := func(name, value string) {
+		fmt.Fprintf(out, `<ac:parameter ac:name="%s">%s</ac:parameter>`, name, xmlEscape(value))
+	}
+	if fi.language != "" {
+		param("language", fi.language)
+	}
+	if fi.title != "" {
+		param("title", fi.title)
+	}
+	if fi.linenos {
+		param("linenumbers", "true")
+		if fi.start > 1 {
+			param("firstline", strconv.Itoa(fi.start))
+		}
+	}
+	var b strings.Builder
+	for i := 0; i < n.Lines().Len(); i++ {
+		seg := n.Lines().At(i)
+		b.Write(seg.Value(w.source))
+	}
+	fmt.Fprintf(out, "<ac:plain-text-body>%s</ac:plain-text-body></ac:structured-macro>\n", confluenceCDATA(strings.TrimRight(b.String(), "\n")))
+}
+
+// list converts a list; lists of tasks only become Confluence task lists
+func (w *confluenceWriter) list(n *ast.List) {
+	out := w.out
+	tasks := n.ChildCount() > 0
+	for item := n.FirstChild(); item != nil && tasks; item = item.NextSibling() {
+		tasks = taskCheckBox(item) != nil
+	}
+	if tasks {
+		out.WriteString("<ac:task-list>\n")
+		for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+			status := "incomplete"
+			if taskCheckBox(item).IsChecked {
+				status = "complete"
+			}
+			fmt.Fprintf(out, "<ac:task><ac:task-status>%s</ac:task-status><ac:task-body>", status)
+			for c := item.FirstChild(); c != nil; c = c.NextSibling() {
+				if c.Kind() != ast.KindTextBlock && c.Kind() != ast.KindParagraph {
+					w.block(c)
+					continue
+				}
+				// the status shows the checkbox
+				for ic := c.FirstChild(); ic != nil; ic = ic.NextSibling() {
+					if ic.Kind() != east.KindTaskCheckBox {
+						w.inline(ic)
+					}
+				}
+			}
+			out.WriteString("</ac:task-body></ac:task>\n")
+		}
+		out.WriteString("</ac:task-list>\n")
+		return
+	}
+	tag := "ul"
+	if n.IsOrdered() {
+		tag = "ol"
+	}
+	out.WriteString("<" + tag)
+	if n.IsOrdered() && n.Start != 1 {
+		fmt.Fprintf(out, ` start="%d"`, n.Start)
+	}
+	out.WriteString(">\n")
+	for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+		out.WriteString("<li>")
+		// items of tight lists are text w/o paragraphs
+		for c := item.FirstChild(); c != nil; c = c.NextSibling() {
+			if c.Kind() == ast.KindTextBlock {
+				w.inlines(c)
+				continue
+			}
+			w.block(c)
+		}
+		out.WriteString("</li>\n")
+	}
+	out.WriteString("</" + tag + ">\n")
+}
+
+// taskCheckBox returns the checkbox a list item starts w/, if any
+func taskCheckBox(item ast.Node) *east.TaskCheckBox {
+	if c := item.FirstChild(); c != nil {
+		if box, ok := c.FirstChild().(*east.TaskCheckBox); ok {
+			return box
+		}
+	}
+	return nil
+}
+
+func (w *confluenceWriter) inlines(parent ast.Node) {
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		w.inline(c)
+	}
+}
+
+func (w *confluenceWriter) inline(n ast.Node) {
+	out := w.out
+	switch n := n.(type) {
+	case *ast.Text:
+		out.WriteString(xmlEscape(leafText(n, w.source)))
+		if n.HardLineBreak() {
+			out.WriteString("<br/>")
+		} else if n.SoftLineBreak() {
+			out.WriteString("\n")
+		}
+	case *ast.String:
+		out.WriteString(xmlEscape(leafText(n, w.source)))
+	case *ast.CodeSpan:
+		out.WriteString("<code>" + xmlEscape(plainText(n, w.source)) + "</code>")
+	case *mathInline:
+		out.WriteString("<code>" + xmlEscape(mathText(n, w.source)) + "</code>")
+	case *ast.Emphasis:
+		tag := "em"
+		if n.Level >= 2 {
+			tag = "strong"
+		}
+		out.WriteString("<" + tag + ">")
+		w.inlines(n)
+		out.WriteString("</" + tag + ">")
+	case *east.Strikethrough:
+		out.WriteString(`<span style="text-decoration: line-through;">`)
+		w.inlines(n)
+		out.WriteString("</span>")
+	case *inlineTag:
+		open, closing := "<"+n.syntax.tag+">", "</"+n.syntax.tag+">"
+		switch n.syntax.tag {
+		case "mark":
+			open, closing = `<span style="background-color: rgb(255,240,179);">`, "</span>"
+		case "kbd":
+			open, closing = "<code>", "</code>"
+		}
+		out.WriteString(open)
+		w.inlines(n)
+		out.WriteString(closing)
+	case *ast.Link:
+		w.link(string(n.Destination), func() { w.inlines(n) })
+	case *ast.AutoLink:
+		url := string(n.URL(w.source))
+		if n.AutoLinkType == ast.AutoLinkEmail && !strings.HasPrefix(strings.ToLower(url), "mailto:") {
+			url = "mailto:" + url
+		}
+		w.link(url, func() { out.WriteString(xmlEscape(string(n.Label(w.source)))) })
+	case *wikiLink:
+		w.link(n.href, func() { w.inlines(n) })
+	case *ast.Image:
+		w.image(n)
+	case *east.TaskCheckBox:
+		if n.IsChecked {
+			out.WriteString("☑ ")
+		} else {
+			out.WriteString("☐ ")
+		}
+	case *east.FootnoteLink:
+		fn, ok := w.footnotes[n.Index]
+		if !ok {
+			return
+		}
+		num, ok := w.noteNums[n.Index]
+		if !ok {
+			w.notes = append(w.notes, "")
+			num = len(w.notes)
+			w.noteNums[n.Index] = num
+			// footnotes go to the end, so convert them aside
+			w.out = &strings.Builder{}
+			w.blocks(fn)
+			w.notes[num-1] = w.out.String()
+			w.out = out
+		}
+		out.WriteString("<sup>")
+		w.anchorLink("fn-"+strconv.Itoa(num), strconv.Itoa(num))
+		out.WriteString("</sup>")
+	case *ast.RawHTML, *east.FootnoteBacklink, *imageAttrs:
+	default:
+		w.inlines(n)
+	}
+}
+
+// link writes a link w/ given body; links within the doc point to anchors of headings
+func (w *confluenceWriter) link(dest string, body func()) {
+	if strings.HasPrefix(dest, "#") {
+		outer := w.out
+		w.out = &strings.Builder{}
+		body()
+		label := w.out.String()
+		w.out = outer
+		fmt.Fprintf(w.out, `<ac:link ac:anchor="%s"><ac:link-body>%s</ac:link-body></ac:link>`, xmlEscape(dest[1:]), label)
+		return
+	}
+	fmt.Fprintf(w.out, `<a href="%s">`, xmlEscape(dest))
+	body()
+	w.out.WriteString("</a>")
+}
+
+// anchorLink writes a link to an anchor of the page w/ plain text label
+func (w *confluenceWriter) anchorLink(anchor, label string) {
+	fmt.Fprintf(w.out, `<ac:link ac:anchor="%s"><ac:plain-text-link-body>%s</ac:plain-text-link-body></ac:link>`, xmlEscape(anchor), confluenceCDATA(label))
+}
+
+// image writes an image; local images are attachments of the page, by file name
+func (w *confluenceWriter) image(n *ast.Image) {
+	dest := string(n.Destination)
+	fmt.Fprintf(w.out, `<ac:image ac:alt="%s"`, xmlEscape(plainText(n, w.source)))
+	if v, ok := n.AttributeString("width"); ok {
+		fmt.Fprintf(w.out, ` ac:width="%s"`, xmlEscape(string(v.([]byte))))
+	}
+	if strings.Contains(dest, "://") {
+		fmt.Fprintf(w.out, `><ri:url ri:value="%s"/></ac:image>`, xmlEscape(dest))
+		return
+	}
+	fmt.Fprintf(w.out, `><ri:attachment ri:filename="%s"/></ac:image>`, xmlEscape(path.Base(dest)))
+}
+
+// confluenceAnchor returns an anchor macro by given name
+func confluenceAnchor(name string) string {
+	return `<ac:structured-macro ac:name="anchor"><ac:parameter ac:name="">` + xmlEscape(name) + `</ac:parameter></ac:structured-macro>`
+}
+
+// confluenceAlign returns the style attribute aligning the ith column of a table, if any
+func confluenceAlign(aligns []east.Alignment, i int) string {
+	if i >= len(aligns) {
+		return ""
+	}
+	switch aligns[i] {
+	case east.AlignLeft:
+		return ` style="text-align: left;"`
+	case east.AlignCenter:
+		return ` style="text-align: center;"`
+	case east.AlignRight:
+		return ` style="text-align: right;"`
+	}
+	return ""
+}
+
+// confluenceCDATA returns s as CDATA section, splitting it where s contains the end of one
+func confluenceCDATA(s string) string {
+	return "<![CDATA[" + strings.ReplaceAll(s, "]]>", "]]]]><![CDATA[>") + "]]>"
+}
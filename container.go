@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var kindContainer = ast.NewNodeKind("Container")
+
+// containerOpenRe matches opening fences like `::: details "Click to expand"` or `::: warning Deprecated`
+var containerOpenRe = regexp.MustCompile(`^ {0,3}(:{3,})\s*([\w-]+)[ \t]*(.*?)\s*$`)
+
+// containers rendered as GitHub alerts; others become plain divs
+var alertContainers = map[string]string{
+	"note": "note", "info": "note", "tip": "tip", "important": "important", "warning": "warning",
+	"caution": "caution", "danger": "caution",
+}
+
+// gitHubAlertRe matches the marker line GitHub alerts start w/, like `> [!WARNING]`
+var gitHubAlertRe = regexp.MustCompile(`(?i)^\[!(note|tip|important|warning|caution)\]\s*$`)
+
+// blockquoteAlert returns the alert type of a blockquote written as GitHub alert, if it is 1, and the
+// number of inline nodes of its 1st paragraph making up the marker line
+func blockquoteAlert(bq *ast.Blockquote, source []byte) (string, int) {
+	p, ok := bq.FirstChild().(*ast.Paragraph)
+	if !ok || p.Lines().Len() == 0 {
+		return "", 0
+	}
+	first := p.Lines().At(0)
+	m := gitHubAlertRe.FindSubmatch(first.Value(source))
+	if m == nil {
+		return "", 0
+	}
+	count := 0
+	for c := p.FirstChild(); c != nil; c = c.NextSibling() {
+		count++
+		if t, ok := c.(*ast.Text); ok && (t.SoftLineBreak() || t.HardLineBreak()) {
+			break
+		}
+	}
+	return strings.ToLower(string(m[1])), count
+}
+
+// containerBlock is a fenced container holding nested Markdown:
+//
+//	::: details "Click to expand"
+//	...
+//	:::
+type containerBlock struct {
+	ast.BaseBlock
+	// container type e.g. details or warning
+	name string
+	// rest of the opening fence as written, and its unquoted form
+	info, title string
+}
+
+func (n *containerBlock) Kind() ast.NodeKind {
+	return kindContainer
+}
+
+func (n *containerBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Name": n.name, "Title": n.title}, nil)
+}
+
+// containerSyntax is the goldmark extension of container blocks
+type containerSyntax struct{}
+
+func (containerSyntax) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithBlockParsers(util.Prioritized(containerParser{}, 150)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(containerHTMLRenderer{}, 500)))
+}
+
+type containerParser struct{}
+
+func (containerParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+func (containerParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	m := containerOpenRe.FindSubmatch(line)
+	if m == nil {
+		return nil, parser.NoChildren
+	}
+	info := string(m[3])
+	node := &containerBlock{name: strings.ToLower(string(m[2])), info: info, title: unquote(info)}
+	reader.Advance(len(bytes.TrimRight(line, "\r\n")))
+	return node, parser.HasChildren
+}
+
+func (containerParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, _ := reader.PeekLine()
+	if !isContainerClose(line) {
+		return parser.Continue | parser.HasChildren
+	}
+	// the fence may close a container or code block nested in this one instead
+	blocks := pc.OpenedBlocks()
+	for i := len(blocks) - 1; i >= 0 && blocks[i].Node != node; i-- {
+		switch blocks[i].Node.(type) {
+		case *containerBlock, *ast.FencedCodeBlock:
+			return parser.Continue | parser.HasChildren
+		}
+	}
+	reader.Advance(len(bytes.TrimRight(line, "\r\n")))
+	return parser.Close
+}
+
+func (containerParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (containerParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (containerParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+func isContainerClose(line []byte) bool {
+	s := strings.TrimSpace(string(line))
+	return len(s) >= 3 && strings.Trim(s, ":") == "" && len(line)-len(strings.TrimLeft(string(line), " ")) < 4
+}
+
+type containerHTMLRenderer struct{}
+
+func (containerHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindContainer, renderContainer)
+}
+
+func renderContainer(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*containerBlock)
+	alert, isAlert := alertContainers[n.name]
+	switch {
+	case n.name == "details" && entering:
+		title := n.title
+		if title == "" {
+			title = "Details"
+		}
+		_, _ = w.WriteString("<details>\n<summary>" + html.EscapeString(title) + "</summary>\n")
+	case n.name == "details":
+		_, _ = w.WriteString("</details>\n")
+	case isAlert && entering:
+		title := n.title
+		if title == "" {
+			title = strings.ToUpper(n.name[:1]) + n.name[1:]
+		}
+		_, _ = w.WriteString(`<div class="markdown-alert markdown-alert-` + alert + `">` + "\n")
+		_, _ = w.WriteString(`<p class="markdown-alert-title">` + html.EscapeString(title) + "</p>\n")
+	case entering:
+		_, _ = w.WriteString(`<div class="markdown-container markdown-container-` + html.EscapeString(n.name) + `">` + "\n")
+		if n.title != "" {
+			_, _ = w.WriteString(`<p class="markdown-container-title"><strong>` + html.EscapeString(n.title) + "</strong></p>\n")
+		}
+	default:
+		_, _ = w.WriteString("</div>\n")
+	}
+	return ast.WalkContinue, nil
+}
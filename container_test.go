@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestContainers(t *testing.T) {
+	tests := []struct {
+		name, md, want string
+	}{
+		{
+			name: "details",
+			md:   "::: details \"Click to expand\"\nHidden *by default*.\n:::",
+			want: "<details>\n<summary>Click to expand</summary>\n<p>Hidden <em>by default</em>.</p>\n</details>",
+		},
+		{
+			name: "details w/o title",
+			md:   "::: details\na\n:::",
+			want: "<details>\n<summary>Details</summary>\n<p>a</p>\n</details>",
+		},
+		{
+			name: "alert alias",
+			md:   "::: info\na\n:::",
+			want: "<div class=\"markdown-alert markdown-alert-note\">\n<p class=\"markdown-alert-title\">Info</p>\n<p>a</p>\n</div>",
+		},
+		{
+			name: "alert w/ title",
+			md:   "::: Warning Deprecated\na\n:::",
+			want: "<div class=\"markdown-alert markdown-alert-warning\">\n<p class=\"markdown-alert-title\">Deprecated</p>\n<p>a</p>\n</div>",
+		},
+		{
+			name: "other name",
+			md:   "::: aside Side <note>\nb\n:::",
+			want: "<div class=\"markdown-container markdown-container-aside\">\n<p class=\"markdown-container-title\"><strong>Side &lt;note&gt;</strong></p>\n<p>b</p>\n</div>",
+		},
+		{
+			name: "nested",
+			md:   "::: warning\n```\n:::\n```\n:::: tip\nc\n::::\n:::\nafter",
+			want: "<div class=\"markdown-alert markdown-alert-warning\">\n<p class=\"markdown-alert-title\">Warning</p>\n<pre><code>:::\n</code></pre>\n" +
+				"<div class=\"markdown-alert markdown-alert-tip\">\n<p class=\"markdown-alert-title\">Tip</p>\n<p>c</p>\n</div>\n</div>\n<p>after</p>",
+		},
+		{
+			name: "interrupting a paragraph",
+			md:   "para\n::: note\nx",
+			want: "<p>para</p>\n<div class=\"markdown-alert markdown-alert-note\">\n<p class=\"markdown-alert-title\">Note</p>\n<p>x</p>\n</div>",
+		},
+		{
+			name: "indented code",
+			md:   "    ::: note\n    x",
+			want: "<pre><code>::: note\nx\n</code></pre>",
+		},
+	}
+	md, err := markdownFor(markdownOptions{profile: "gfm-doc", ext: "+container"}, nil, &config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := md.Convert([]byte(tt.md), &out); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(out.String()); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var kindCSVTable = ast.NewNodeKind("CSVTable")
+
+// csvTable is tabular data from a fenced csv or tsv code block, or from a standalone image
+// referencing a .csv or .tsv file, e.g.
+//
+//	```csv header=false delimiter=";" maxrows=50
+//	![table](data/latency.csv){maxrows=50}
+type csvTable struct {
+	ast.BaseBlock
+	rows   [][]string
+	header bool
+	// number of rows left out per maxrows
+	omitted int
+	// the block the table was written as, for formatting it back
+	orig ast.Node
+}
+
+func (n *csvTable) Kind() ast.NodeKind {
+	return kindCSVTable
+}
+
+func (n *csvTable) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Rows": strconv.Itoa(len(n.rows))}, nil)
+}
+
+// csvOptions controls how data is read and shown
+type csvOptions struct {
+	header    bool
+	delimiter rune
+	maxRows   int
+}
+
+// parseCSVOptions reads options from the attributes of a block; the delimiter defaults per format
+func parseCSVOptions(format string, attr func(name string) (string, bool)) (csvOptions, error) {
+	opts := csvOptions{header: true, delimiter: ','}
+	if format == "tsv" {
+		opts.delimiter = '\t'
+	}
+	if v, ok := attr("header"); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid header=%s: %w", v, err)
+		}
+		opts.header = b
+	}
+	if v, ok := attr("delimiter"); ok {
+		switch v {
+		case `\t`, "tab":
+			v = "\t"
+		}
+		r, size := utf8.DecodeRuneInString(v)
+		if size == 0 || size != len(v) {
+			return opts, fmt.Errorf("invalid delimiter=%q: expect a single character", v)
+		}
+		opts.delimiter = r
+	}
+	if v, ok := attr("maxrows"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("invalid maxrows=%s: expect a non-negative number", v)
+		}
+		opts.maxRows = n
+	}
+	return opts, nil
+}
+
+// newCSVTable parses data per opts into a table standing in for orig
+func newCSVTable(r io.Reader, opts csvOptions, orig ast.Node) (*csvTable, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = opts.delimiter
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = opts.delimiter == '\t'
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	n := &csvTable{header: opts.header, orig: orig}
+	limit := opts.maxRows
+	if limit > 0 && opts.header {
+		// the header row does not count
+		limit++
+	}
+	if limit > 0 && len(rows) > limit {
+		n.omitted = len(rows) - limit
+		rows = rows[:limit]
+	}
+	n.rows = rows
+	n.SetLines(orig.Lines())
+	return n, nil
+}
+
+// attributes an attribute list may set on images referencing tables: imageAttrNames plus the options
+// of csvOptions
+var csvImageAttrNames = map[string]bool{
+	"width": true, "height": true, "class": true, "id": true,
+	"header": true, "delimiter": true, "maxrows": true,
+}
+
+// csvSyntax is the goldmark extension rendering CSV and TSV data as tables; referenced files resolve
+// relative to docDir
+type csvSyntax struct {
+	docDir string
+	// problems warned about already; docs get parsed more than once e.g. to expand includes
+	warned map[string]bool
+}
+
+func newCSVSyntax(docDir string) *csvSyntax {
+	return &csvSyntax{docDir: docDir, warned: map[string]bool{}}
+}
+
+func (s *csvSyntax) Extend(m goldmark.Markdown) {
+	// before figures, which would take standalone images otherwise
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(s, 300)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(s, 500)))
+}
+
+func (s *csvSyntax) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	var candidates []ast.Node
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n.(type) {
+		case *ast.FencedCodeBlock, *ast.Paragraph:
+			candidates = append(candidates, n)
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, n := range candidates {
+		var table *csvTable
+		var err error
+		switch n := n.(type) {
+		case *ast.FencedCodeBlock:
+			table, err = s.fromCode(n, source)
+		case *ast.Paragraph:
+			table, err = s.fromImage(n, source)
+		}
+		if err != nil {
+			if msg := err.Error(); !s.warned[msg] {
+				s.warned[msg] = true
+				fmt.Fprintf(os.Stderr, "warning: %s\n", msg)
+			}
+			continue
+		}
+		if table != nil {
+			n.Parent().ReplaceChild(n.Parent(), n, table)
+		}
+	}
+}
+
+func (s *csvSyntax) fromCode(n *ast.FencedCodeBlock, source []byte) (*csvTable, error) {
+	if n.Info == nil {
+		return nil, nil
+	}
+	info := string(n.Info.Segment.Value(source))
+	fi := parseFenceInfo(info)
+	format := strings.ToLower(fi.language)
+	if format != "csv" && format != "tsv" {
+		return nil, nil
+	}
+	opts, err := parseCSVOptions(format, func(name string) (string, bool) {
+		v, ok := fi.attrs[name]
+		return v, ok
+	})
+	if err != nil {
+		return nil, fmt.Errorf("code block %q: %w", info, err)
+	}
+	var data strings.Builder
+	for i := 0; i < n.Lines().Len(); i++ {
+		seg := n.Lines().At(i)
+		data.Write(seg.Value(source))
+	}
+	table, err := newCSVTable(strings.NewReader(data.String()), opts, n)
+	if err != nil {
+		return nil, fmt.Errorf("code block %q: %w", info, err)
+	}
+	return table, nil
+}
+
+func (s *csvSyntax) fromImage(p *ast.Paragraph, source []byte) (*csvTable, error) {
+	img, ok := p.FirstChild().(*ast.Image)
+	if !ok {
+		return nil, nil
+	}
+	dest := string(img.Destination)
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(dest)), ".")
+	if format != "csv" && format != "tsv" {
+		return nil, nil
+	}
+	path, ok := localPath(dest, s.docDir)
+	if !ok {
+		return nil, nil
+	}
+	if t, ok := img.NextSibling().(*ast.Text); ok {
+		takeImageAttrs(img, t, source, csvImageAttrNames)
+	}
+	if standaloneImage(p, source) != img {
+		return nil, nil
+	}
+	opts, err := parseCSVOptions(format, func(name string) (string, bool) {
+		v, ok := img.AttributeString(name)
+		if !ok {
+			return "", false
+		}
+		return string(v.([]byte)), true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("table %s: %w", dest, err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading table data: %w", err)
+	}
+	defer f.Close()
+	table, err := newCSVTable(f, opts, p)
+	if err != nil {
+		return nil, fmt.Errorf("table %s: %w", dest, err)
+	}
+	return table, nil
+}
+
+func (s *csvSyntax) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindCSVTable, renderCSVTable)
+}
+
+// renderCSVTable renders the table the way GitHub shows CSV files, w/ row numbers in the first column
+func renderCSVTable(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*csvTable)
+	columns := 0
+	for _, row := range n.rows {
+		columns = max(columns, len(row))
+	}
+	writeRow := func(num int, row []string, cell string) {
+		_, _ = w.WriteString("<tr>\n")
+		_, _ = fmt.Fprintf(w, "<%s class=\"blob-num\">%d</%s>\n", cell, num, cell)
+		for i := 0; i < columns; i++ {
+			var v string
+			if i < len(row) {
+				v = row[i]
+			}
+			_, _ = w.WriteString("<" + cell + ">" + html.EscapeString(v) + "</" + cell + ">\n")
+		}
+		_, _ = w.WriteString("</tr>\n")
+	}
+	_, _ = w.WriteString("<table class=\"csv-data\">\n")
+	rows := n.rows
+	if n.header && len(rows) > 0 {
+		_, _ = w.WriteString("<thead>\n")
+		writeRow(1, rows[0], "th")
+		_, _ = w.WriteString("</thead>\n")
+		rows = rows[1:]
+	}
+	_, _ = w.WriteString("<tbody>\n")
+	for i, row := range rows {
+		writeRow(len(n.rows)-len(rows)+i+1, row, "td")
+	}
+	if n.omitted > 0 {
+		more := fmt.Sprintf("%d more rows", n.omitted)
+		if n.omitted == 1 {
+			more = "1 more row"
+		}
+		_, _ = fmt.Fprintf(w, "<tr>\n<td class=\"blob-num\"></td>\n<td colspan=\"%d\"><em>%s</em></td>\n</tr>\n", max(columns, 1), more)
+	}
+	_, _ = w.WriteString("</tbody>\n</table>\n")
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCSVTables(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data.csv"), []byte("a,b\n1,2\n3,4\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, md, want, warning string
+	}{
+		{
+			name: "header",
+			md:   "```csv\nname,n\n<a>,1\n```",
+			want: "<table class=\"csv-data\">\n<thead>\n<tr>\n<th class=\"blob-num\">1</th>\n<th>name</th>\n<th>n</th>\n</tr>\n</thead>\n" +
+				"<tbody>\n<tr>\n<td class=\"blob-num\">2</td>\n<td>&lt;a&gt;</td>\n<td>1</td>\n</tr>\n</tbody>\n</table>",
+		},
+		{
+			name: "tsv w/o header, ragged rows",
+			md:   "```tsv header=false\na\tb,\"c\nd\n```",
+			want: "<table class=\"csv-data\">\n<tbody>\n<tr>\n<td class=\"blob-num\">1</td>\n<td>a</td>\n<td>b,&#34;c</td>\n</tr>\n" +
+				"<tr>\n<td class=\"blob-num\">2</td>\n<td>d</td>\n<td></td>\n</tr>\n</tbody>\n</table>",
+		},
+		{
+			name: "delimiter and maxrows",
+			md:   "```csv delimiter=\";\" maxrows=1\nx;y\n1;2\n3;4\n5;6\n```",
+			want: "<table class=\"csv-data\">\n<thead>\n<tr>\n<th class=\"blob-num\">1</th>\n<th>x</th>\n<th>y</th>\n</tr>\n</thead>\n" +
+				"<tbody>\n<tr>\n<td class=\"blob-num\">2</td>\n<td>1</td>\n<td>2</td>\n</tr>\n" +
+				"<tr>\n<td class=\"blob-num\"></td>\n<td colspan=\"2\"><em>2 more rows</em></td>\n</tr>\n</tbody>\n</table>",
+		},
+		{
+			name: "file",
+			md:   "![table](data.csv){maxrows=1 header=false}",
+			want: "<table class=\"csv-data\">\n<tbody>\n<tr>\n<td class=\"blob-num\">1</td>\n<td>a</td>\n<td>b</td>\n</tr>\n" +
+				"<tr>\n<td class=\"blob-num\"></td>\n<td colspan=\"2\"><em>2 more rows</em></td>\n</tr>\n</tbody>\n</table>",
+		},
+		{
+			name: "image within text",
+			md:   "see ![table](data.csv)",
+			want: `<p>see <img src="data.csv" alt="table"></p>`,
+		},
+		{
+			name:    "invalid option",
+			md:      "```csv maxrows=x\na\n```",
+			want:    "<pre><code class=\"language-csv\">a\n</code></pre>",
+			warning: "warning: code block \"csv maxrows=x\": invalid maxrows=x: expect a non-negative number\n",
+		},
+		{
+			name:    "missing file",
+			md:      "![table](missing.csv)",
+			want:    `<p><img src="missing.csv" alt="table"></p>`,
+			warning: "warning: error reading table data: open " + filepath.Join(dir, "missing.csv") + ": no such file or directory\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md, err := markdownFor(markdownOptions{profile: "gfm-doc", ext: "+csv", docDir: dir}, nil, &config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			warning := captureStderr(t, func() {
+				err = md.Convert([]byte(tt.md), &out)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(out.String()); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if warning != tt.warning {
+				t.Errorf("got warning %q, want %q", warning, tt.warning)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// number of unchanged lines shown around each change in unified diffs
+const diffContext = 3
+
+type diffOp struct {
+	// one of ' ', '-' and '+'
+	kind byte
+	line string
+}
+
+// diffLines computes a shortest edit script turning a into b (Myers' algorithm)
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	if n+m == 0 {
+		return nil
+	}
+	off := n + m
+	v := make([]int, 2*off+2)
+	// v of every round is kept to backtrack the edit path afterwards
+	var trace [][]int
+search:
+	for d := 0; d <= off; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var ops []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[off+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			ops = append(ops, diffOp{'+', b[y-1]})
+			y--
+		} else {
+			ops = append(ops, diffOp{'-', a[x-1]})
+			x--
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// unifiedDiff returns the unified diff between a and b, or nil if they are equal
+func unifiedDiff(oldName, newName string, a, b []byte) []byte {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+	ops := diffLines(splitLines(a), splitLines(b))
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	// line numbers (0-based) in a and b at which each op starts
+	oldAt := make([]int, len(ops)+1)
+	newAt := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldAt[i+1], newAt[i+1] = oldAt[i], newAt[i]
+		if op.kind != '+' {
+			oldAt[i+1]++
+		}
+		if op.kind != '-' {
+			newAt[i+1]++
+		}
+	}
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// extend the hunk as long as changes are no more than 2*diffContext lines apart
+		start := max(0, i-diffContext)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		end = min(len(ops), end+diffContext)
+		oldStart, oldCount := oldAt[start], oldAt[end]-oldAt[start]
+		newStart, newCount := newAt[start], newAt[end]-newAt[start]
+		// by convention an empty range refers to the line before it
+		if oldCount > 0 {
+			oldStart++
+		}
+		if newCount > 0 {
+			newStart++
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			out.WriteByte('\n')
+		}
+		i = end
+	}
+	return out.Bytes()
+}
+
+func splitLines(b []byte) []string {
+	s := strings.TrimSuffix(string(b), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
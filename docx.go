@@ -0,0 +1,751 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// relationship types of Office Open XML packages
+const (
+	docxRelOfficeDocument = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument"
+	docxRelCoreProperties = "http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties"
+	docxRelStyles         = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles"
+	docxRelNumbering      = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering"
+	docxRelFootnotes      = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/footnotes"
+	docxRelHyperlink      = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink"
+	docxRelImage          = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/image"
+)
+
+// namespaces of document and footnotes parts
+const docxNamespaces = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" ` +
+	`xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing" ` +
+	`xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" ` +
+	`xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture"`
+
+// images Word takes, by file extension
+var docxImageTypes = map[string]string{".gif": "image/gif", ".jpeg": "image/jpeg", ".jpg": "image/jpeg", ".png": "image/png"}
+
+const (
+	// English Metric Units per pixel at 96 dpi
+	docxEMUPerPixel = 9525
+	// width of the text area of a letter page w/ 1 inch margins
+	docxMaxImageWidth = 6 * 914400
+	// indentation per list level, in twentieths of a point
+	docxIndent = 720
+)
+
+// docxPart is a part of the package holding content, along w/ its relationships
+type docxPart struct {
+	out  strings.Builder
+	rels []docxRel
+}
+
+type docxRel struct {
+	id, typ, target string
+	external        bool
+}
+
+// addRel returns the ID of a new relationship of the part; IDs up to rId3 are taken by the parts the
+// document refers to
+func (p *docxPart) addRel(typ, target string, external bool) string {
+	id := "rId" + strconv.Itoa(len(p.rels)+4)
+	p.rels = append(p.rels, docxRel{id: id, typ: typ, target: target, external: external})
+	return id
+}
+
+// docxFormat is the character formatting of runs
+type docxFormat struct {
+	bold, italic, strike, code, link, mark, sub, sup bool
+}
+
+// docxBlockContext is how blocks get laid out where they are
+type docxBlockContext struct {
+	// paragraph style
+	style string
+	// left indentation in twentieths of a point, e.g. of paragraphs continuing a list item
+	indent int
+	// list nesting level, and numbering of the next paragraph where numID is 0 for none
+	depth, numID int
+	// runs to put at the start of the next paragraph
+	prefix string
+}
+
+// docxWriter converts a goldmark AST to WordprocessingML
+type docxWriter struct {
+	source []byte
+	// directory local images resolve against
+	dir       string
+	document  docxPart
+	footnotes docxPart
+	// the part being written
+	part *docxPart
+	// footnote definitions by index
+	footnoteDefs map[int]*east.Footnote
+	footnoteID   int
+	// list numbering instances; ordered ones start at given number, others are bullets
+	nums []struct {
+		ordered bool
+		start   int
+	}
+	// files under word/media by name
+	media      map[string][]byte
+	mediaNames map[string]string
+	// IDs of drawings and bookmarks
+	drawingID, bookmarkID int
+}
+
+// renderDOCX renders the doc as a Word document
+func renderDOCX(w io.Writer, md goldmark.Markdown, doc *document) error {
+	root := md.Parser().Parse(text.NewReader(doc.source))
+	dw := &docxWriter{
+		source:       doc.source,
+		dir:          inputDir(doc.path),
+		footnoteDefs: map[int]*east.Footnote{},
+		media:        map[string][]byte{},
+		mediaNames:   map[string]string{},
+	}
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if fn, ok := n.(*east.Footnote); ok && entering {
+			dw.footnoteDefs[fn.Index] = fn
+		}
+		return ast.WalkContinue, nil
+	})
+	dw.part = &dw.document
+	dw.blocks(root, &docxBlockContext{})
+
+	zw := zip.NewWriter(w)
+	add := func(name, content string) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(f, content)
+		return err
+	}
+	files := []struct{ name, content string }{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxRels(nil, []docxRel{
+			{id: "rId1", typ: docxRelOfficeDocument, target: "word/document.xml"},
+			{id: "rId2", typ: docxRelCoreProperties, target: "docProps/core.xml"},
+		})},
+		{"docProps/core.xml", docxCoreProperties(doc.title(root), doc.meta["author"], doc.meta["description"])},
+		{"word/document.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+			`<w:document ` + docxNamespaces + `><w:body>` + dw.document.out.String() +
+			`<w:sectPr><w:pgSz w:w="12240" w:h="15840"/><w:pgMar w:top="1440" w:right="1440" w:bottom="1440" w:left="1440" w:header="720" w:footer="720" w:gutter="0"/></w:sectPr>` +
+			`</w:body></w:document>`},
+		{"word/_rels/document.xml.rels", docxRels([]docxRel{
+			{id: "rId1", typ: docxRelStyles, target: "styles.xml"},
+			{id: "rId2", typ: docxRelNumbering, target: "numbering.xml"},
+			{id: "rId3", typ: docxRelFootnotes, target: "footnotes.xml"},
+		}, dw.document.rels)},
+		{"word/styles.xml", docxStyles},
+		{"word/numbering.xml", dw.numbering()},
+		{"word/footnotes.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+			`<w:footnotes ` + docxNamespaces + `>` +
+			`<w:footnote w:type="separator" w:id="-1"><w:p><w:r><w:separator/></w:r></w:p></w:footnote>` +
+			`<w:footnote w:type="continuationSeparator" w:id="0"><w:p><w:r><w:continuationSeparator/></w:r></w:p></w:footnote>` +
+			dw.footnotes.out.String() + `</w:footnotes>`},
+		{"word/_rels/footnotes.xml.rels", docxRels(nil, dw.footnotes.rels)},
+	}
+	for _, f := range files {
+		if err := add(f.name, f.content); err != nil {
+			return err
+		}
+	}
+	for name, data := range dw.media {
+		if err := add("word/media/"+name, string(data)); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// xmlEscape escapes s for XML text and attribute values
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func docxRels(fixed, rels []docxRel) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for _, r := range append(fixed, rels...) {
+		fmt.Fprintf(&b, `<Relationship Id="%s" Type="%s" Target="%s"`, r.id, r.typ, xmlEscape(r.target))
+		if r.external {
+			b.WriteString(` TargetMode="External"`)
+		}
+		b.WriteString("/>")
+	}
+	b.WriteString("</Relationships>")
+	return b.String()
+}
+
+func docxCoreProperties(title, creator, description string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	b.WriteString(`<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" ` +
+		`xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" ` +
+		`xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">`)
+	if title != "" {
+		b.WriteString("<dc:title>" + xmlEscape(title) + "</dc:title>")
+	}
+	if creator != "" {
+		b.WriteString("<dc:creator>" + xmlEscape(creator) + "</dc:creator>")
+	}
+	if description != "" {
+		b.WriteString("<dc:description>" + xmlEscape(description) + "</dc:description>")
+	}
+	fmt.Fprintf(&b, `<dcterms:created xsi:type="dcterms:W3CDTF">%s</dcterms:created>`, time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+	b.WriteString("</cp:coreProperties>")
+	return b.String()
+}
+
+// numbering returns the numbering part: 1 abstract numbering for bullets, 1 for ordered lists, and an
+// instance of either per list so that every ordered list counts from its own start
+func (w *docxWriter) numbering() string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	b.WriteString(`<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">`)
+	bullets := []string{"•", "◦", "▪"}
+	for abstract, ordered := range []bool{false, true} {
+		fmt.Fprintf(&b, `<w:abstractNum w:abstractNumId="%d"><w:multiLevelType w:val="hybridMultilevel"/>`, abstract)
+		for lvl := 0; lvl < 9; lvl++ {
+			format, lvlText := "bullet", bullets[lvl%len(bullets)]
+			if ordered {
+				format, lvlText = "decimal", "%"+strconv.Itoa(lvl+1)+"."
+			}
+			fmt.Fprintf(&b, `<w:lvl w:ilvl="%d"><w:start w:val="1"/><w:numFmt w:val="%s"/><w:lvlText w:val="%s"/><w:lvlJc w:val="left"/>`+
+				`<w:pPr><w:ind w:left="%d" w:hanging="360"/></w:pPr></w:lvl>`, lvl, format, lvlText, docxIndent*(lvl+1))
+		}
+		b.WriteString("</w:abstractNum>")
+	}
+	for i, num := range w.nums {
+		if num.ordered {
+			fmt.Fprintf(&b, `<w:num w:numId="%d"><w:abstractNumId w:val="1"/>`, i+1)
+			for lvl := 0; lvl < 9; lvl++ {
+				fmt.Fprintf(&b, `<w:lvlOverride w:ilvl="%d"><w:startOverride w:val="%d"/></w:lvlOverride>`, lvl, num.start)
+			}
+			b.WriteString("</w:num>")
+		} else {
+			fmt.Fprintf(&b, `<w:num w:numId="%d"><w:abstractNumId w:val="0"/></w:num>`, i+1)
+		}
+	}
+	b.WriteString("</w:numbering>")
+	return b.String()
+}
+
+func (w *docxWriter) blocks(parent ast.Node, ctx *docxBlockContext) {
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		w.block(c, ctx)
+	}
+}
+
+func (w *docxWriter) block(n ast.Node, ctx *docxBlockContext) {
+	out := &w.part.out
+	switch n := n.(type) {
+	case *ast.Heading:
+		heading := *ctx
+		heading.style = "Heading" + strconv.Itoa(n.Level)
+		w.paragraph(&heading, func() {
+			// headings are bookmarked by their IDs, for links within the doc
+			if id, ok := n.AttributeString("id"); ok {
+				w.bookmarkID++
+				fmt.Fprintf(out, `<w:bookmarkStart w:id="%d" w:name="%s"/>`, w.bookmarkID, xmlEscape(docxBookmark(string(id.([]byte)))))
+				w.inlines(n, docxFormat{})
+				fmt.Fprintf(out, `<w:bookmarkEnd w:id="%d"/>`, w.bookmarkID)
+				return
+			}
+			w.inlines(n, docxFormat{})
+		})
+		ctx.numID, ctx.prefix = heading.numID, heading.prefix
+	case *ast.Paragraph, *ast.TextBlock:
+		w.paragraph(ctx, func() { w.inlines(n, docxFormat{}) })
+	case *ast.ThematicBreak:
+		out.WriteString(`<w:p><w:pPr><w:pBdr><w:bottom w:val="single" w:sz="6" w:space="1" w:color="auto"/></w:pBdr></w:pPr></w:p>`)
+	case *ast.CodeBlock, *ast.FencedCodeBlock, *mathBlock:
+		code := *ctx
+		code.style = "SourceCode"
+		w.paragraph(&code, func() {
+			lines := n.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				if i > 0 {
+					out.WriteString("<w:r><w:br/></w:r>")
+				}
+				seg := lines.At(i)
+				w.run(strings.TrimRight(string(seg.Value(w.source)), "\r\n"), docxFormat{})
+			}
+		})
+		ctx.numID, ctx.prefix = code.numID, code.prefix
+	case *ast.Blockquote:
+		quote := *ctx
+		quote.style = "Quote"
+		w.blocks(n, &quote)
+		ctx.numID, ctx.prefix = quote.numID, quote.prefix
+	case *ast.List:
+		w.nums = append(w.nums, struct {
+			ordered bool
+			start   int
+		}{n.IsOrdered(), max(n.Start, 1)})
+		numID := len(w.nums)
+		depth := 0
+		if ctx.style == "ListParagraph" {
+			depth = ctx.depth + 1
+		}
+		for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+			itemCtx := &docxBlockContext{style: "ListParagraph", indent: docxIndent * (depth + 1), depth: depth, numID: numID}
+			w.blocks(item, itemCtx)
+		}
+	case *east.Table:
+		w.table(n)
+	case *csvTable:
+		w.csvTable(n)
+	case *east.DefinitionList:
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			if _, ok := c.(*east.DefinitionTerm); ok {
+				w.paragraph(ctx, func() { w.inlines(c, docxFormat{bold: true}) })
+				continue
+			}
+			desc := *ctx
+			desc.indent += docxIndent
+			w.blocks(c, &desc)
+		}
+	case *containerBlock:
+		title := n.title
+		if title == "" {
+			title = strings.ToUpper(n.name[:1]) + n.name[1:]
+		}
+		w.paragraph(ctx, func() { w.run(title, docxFormat{bold: true}) })
+		quote := *ctx
+		quote.style = "Quote"
+		w.blocks(n, &quote)
+	case *figureBlock:
+		out.WriteString(`<w:p><w:pPr><w:jc w:val="center"/></w:pPr>`)
+		w.inlines(n, docxFormat{})
+		out.WriteString("</w:p>")
+		if len(n.caption) > 0 {
+			out.WriteString(`<w:p><w:pPr><w:pStyle w:val="Caption"/><w:jc w:val="center"/></w:pPr>`)
+			w.run(string(n.caption), docxFormat{})
+			out.WriteString("</w:p>")
+		}
+	case *ast.HTMLBlock, *east.FootnoteList:
+		// raw html means nothing to Word; footnotes go w/ their references
+	default:
+		w.blocks(n, ctx)
+	}
+}
+
+// paragraph writes a paragraph laid out per ctx, w/ content written by body
+func (w *docxWriter) paragraph(ctx *docxBlockContext, body func()) {
+	out := &w.part.out
+	var props strings.Builder
+	if ctx.style != "" {
+		props.WriteString(`<w:pStyle w:val="` + ctx.style + `"/>`)
+	}
+	if ctx.numID > 0 {
+		fmt.Fprintf(&props, `<w:numPr><w:ilvl w:val="%d"/><w:numId w:val="%d"/></w:numPr>`, ctx.depth, ctx.numID)
+	} else if ctx.indent > 0 {
+		fmt.Fprintf(&props, `<w:ind w:left="%d"/>`, ctx.indent)
+	}
+	out.WriteString("<w:p>")
+	if props.Len() > 0 {
+		out.WriteString("<w:pPr>" + props.String() + "</w:pPr>")
+	}
+	out.WriteString(ctx.prefix)
+	ctx.numID, ctx.prefix = 0, ""
+	body()
+	out.WriteString("</w:p>")
+}
+
+func (w *docxWriter) inlines(parent ast.Node, f docxFormat) {
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		w.inline(c, f)
+	}
+}
+
+func (w *docxWriter) inline(n ast.Node, f docxFormat) {
+	out := &w.part.out
+	switch n := n.(type) {
+	case *ast.Text:
+		w.run(leafText(n, w.source), f)
+		if n.HardLineBreak() {
+			out.WriteString("<w:r><w:br/></w:r>")
+		} else if n.SoftLineBreak() {
+			w.run(" ", f)
+		}
+	case *ast.String:
+		w.run(leafText(n, w.source), f)
+	case *ast.CodeSpan:
+		f.code = true
+		w.run(plainText(n, w.source), f)
+	case *mathInline:
+		// TeX as written, for lack of a converter to Office math
+		f.code = true
+		w.run(mathText(n, w.source), f)
+	case *ast.Emphasis:
+		if n.Level >= 2 {
+			f.bold = true
+		} else {
+			f.italic = true
+		}
+		w.inlines(n, f)
+	case *east.Strikethrough:
+		f.strike = true
+		w.inlines(n, f)
+	case *inlineTag:
+		switch n.syntax.tag {
+		case "mark":
+			f.mark = true
+		case "sub":
+			f.sub = true
+		case "sup":
+			f.sup = true
+		case "kbd":
+			f.code = true
+		}
+		w.inlines(n, f)
+	case *ast.Link:
+		w.hyperlink(string(n.Destination), f, func(f docxFormat) { w.inlines(n, f) })
+	case *ast.AutoLink:
+		url := string(n.URL(w.source))
+		if n.AutoLinkType == ast.AutoLinkEmail && !strings.HasPrefix(strings.ToLower(url), "mailto:") {
+			url = "mailto:" + url
+		}
+		w.hyperlink(url, f, func(f docxFormat) { w.run(string(n.Label(w.source)), f) })
+	case *wikiLink:
+		if n.href == "" {
+			w.inlines(n, f)
+			return
+		}
+		w.hyperlink(n.href, f, func(f docxFormat) { w.inlines(n, f) })
+	case *ast.Image:
+		w.image(n, f)
+	case *east.TaskCheckBox:
+		if n.IsChecked {
+			w.run("☒ ", f)
+		} else {
+			w.run("☐ ", f)
+		}
+	case *east.FootnoteLink:
+		w.footnoteReference(n.Index)
+	case *ast.RawHTML, *east.FootnoteBacklink, *imageAttrs:
+	default:
+		w.inlines(n, f)
+	}
+}
+
+// run writes text as a run formatted per f
+func (w *docxWriter) run(s string, f docxFormat) {
+	if s == "" {
+		return
+	}
+	out := &w.part.out
+	out.WriteString("<w:r>")
+	var props strings.Builder
+	switch {
+	case f.code:
+		props.WriteString(`<w:rStyle w:val="VerbatimChar"/>`)
+	case f.link:
+		props.WriteString(`<w:rStyle w:val="Hyperlink"/>`)
+	}
+	if f.bold {
+		props.WriteString("<w:b/>")
+	}
+	if f.italic {
+		props.WriteString("<w:i/>")
+	}
+	if f.strike {
+		props.WriteString("<w:strike/>")
+	}
+	if f.mark {
+		props.WriteString(`<w:highlight w:val="yellow"/>`)
+	}
+	if f.sub {
+		props.WriteString(`<w:vertAlign w:val="subscript"/>`)
+	} else if f.sup {
+		props.WriteString(`<w:vertAlign w:val="superscript"/>`)
+	}
+	if props.Len() > 0 {
+		out.WriteString("<w:rPr>" + props.String() + "</w:rPr>")
+	}
+	// tabs are elements of their own
+	for i, part := range strings.Split(s, "\t") {
+		if i > 0 {
+			out.WriteString("<w:tab/>")
+		}
+		if part != "" {
+			out.WriteString(`<w:t xml:space="preserve">` + xmlEscape(part) + "</w:t>")
+		}
+	}
+	out.WriteString("</w:r>")
+}
+
+// hyperlink writes a link to dest w/ runs written by body, formatted per f as a link; `#fragment`
+// links go to bookmarks
+func (w *docxWriter) hyperlink(dest string, f docxFormat, body func(f docxFormat)) {
+	out := &w.part.out
+	if anchor, ok := strings.CutPrefix(dest, "#"); ok {
+		out.WriteString(`<w:hyperlink w:anchor="` + xmlEscape(docxBookmark(anchor)) + `">`)
+	} else {
+		out.WriteString(`<w:hyperlink r:id="` + w.part.addRel(docxRelHyperlink, dest, true) + `">`)
+	}
+	f.link = true
+	body(f)
+	out.WriteString("</w:hyperlink>")
+}
+
+// image writes an inline picture of a local PNG, JPEG or GIF image; others become links to them
+func (w *docxWriter) image(n *ast.Image, f docxFormat) {
+	alt := plainText(n, w.source)
+	dest := string(n.Destination)
+	path, ok := localPath(dest, w.dir)
+	ext := strings.ToLower(filepath.Ext(path))
+	var data []byte
+	var cfg image.Config
+	if ok && docxImageTypes[ext] != "" {
+		var err error
+		if data, err = os.ReadFile(path); err == nil {
+			cfg, _, err = image.DecodeConfig(bytes.NewReader(data))
+		}
+		ok = err == nil && cfg.Width > 0 && cfg.Height > 0
+	} else {
+		ok = false
+	}
+	if !ok {
+		if alt == "" {
+			alt = dest
+		}
+		f.italic = true
+		w.hyperlink(dest, f, func(f docxFormat) { w.run(alt, f) })
+		return
+	}
+	abs, _ := filepath.Abs(path)
+	name, added := w.mediaNames[abs]
+	if !added {
+		name = fmt.Sprintf("image%d%s", len(w.media)+1, ext)
+		w.media[name] = data
+		w.mediaNames[abs] = name
+	}
+	width, height := cfg.Width, cfg.Height
+	// a width given by attributes wins, keeping the aspect ratio
+	if v, ok := n.AttributeString("width"); ok {
+		if px, err := strconv.Atoi(string(v.([]byte))); err == nil && px > 0 {
+			width, height = px, px*cfg.Height/cfg.Width
+		}
+	}
+	cx, cy := width*docxEMUPerPixel, height*docxEMUPerPixel
+	if cx > docxMaxImageWidth {
+		cx, cy = docxMaxImageWidth, cy*docxMaxImageWidth/cx
+	}
+	rel := w.part.addRel(docxRelImage, "media/"+name, false)
+	w.drawingID++
+	fmt.Fprintf(&w.part.out, `<w:r><w:drawing><wp:inline distT="0" distB="0" distL="0" distR="0">`+
+		`<wp:extent cx="%d" cy="%d"/><wp:docPr id="%d" name="Picture %d" descr="%s"/>`+
+		`<a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture">`+
+		`<pic:pic><pic:nvPicPr><pic:cNvPr id="%d" name="%s"/><pic:cNvPicPr/></pic:nvPicPr>`+
+		`<pic:blipFill><a:blip r:embed="%s"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>`+
+		`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="%d" cy="%d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr>`+
+		`</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r>`,
+		cx, cy, w.drawingID, w.drawingID, xmlEscape(alt), w.drawingID, xmlEscape(name), rel, cx, cy)
+}
+
+// footnoteReference writes a reference to the footnote of given index, along w/ the footnote itself;
+// Word footnotes belong to 1 reference each, so footnotes referenced twice are written twice
+func (w *docxWriter) footnoteReference(index int) {
+	def, ok := w.footnoteDefs[index]
+	if !ok {
+		return
+	}
+	w.footnoteID++
+	id := w.footnoteID
+	fmt.Fprintf(&w.part.out, `<w:r><w:rPr><w:rStyle w:val="FootnoteReference"/></w:rPr><w:footnoteReference w:id="%d"/></w:r>`, id)
+
+	saved := w.part
+	w.part = &w.footnotes
+	fmt.Fprintf(&w.part.out, `<w:footnote w:id="%d">`, id)
+	ctx := &docxBlockContext{
+		style:  "FootnoteText",
+		prefix: `<w:r><w:rPr><w:rStyle w:val="FootnoteReference"/></w:rPr><w:footnoteRef/></w:r><w:r><w:t xml:space="preserve"> </w:t></w:r>`,
+	}
+	w.blocks(def, ctx)
+	if ctx.prefix != "" {
+		// footnotes need a paragraph even when empty
+		w.paragraph(ctx, func() {})
+	}
+	w.part.out.WriteString("</w:footnote>")
+	w.part = saved
+}
+
+// table writes a GFM table, w/ its header row repeated on every page
+func (w *docxWriter) table(n *east.Table) {
+	columns := 0
+	for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+		columns = max(columns, row.ChildCount())
+	}
+	w.tableStart(columns)
+	for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+		_, header := row.(*east.TableHeader)
+		w.rowStart(header)
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			align := ""
+			if c, ok := cell.(*east.TableCell); ok {
+				switch c.Alignment {
+				case east.AlignLeft:
+					align = "left"
+				case east.AlignCenter:
+					align = "center"
+				case east.AlignRight:
+					align = "right"
+				}
+			}
+			w.cell(align, func() { w.inlines(cell, docxFormat{bold: header}) })
+		}
+		w.part.out.WriteString("</w:tr>")
+	}
+	w.part.out.WriteString("</w:tbl>")
+	// tables directly following each other would merge
+	w.part.out.WriteString("<w:p/>")
+}
+
+// csvTable writes CSV data as a table
+func (w *docxWriter) csvTable(n *csvTable) {
+	columns := 0
+	for _, row := range n.rows {
+		columns = max(columns, len(row))
+	}
+	w.tableStart(columns)
+	for i, row := range n.rows {
+		header := i == 0 && n.header
+		w.rowStart(header)
+		for j := 0; j < columns; j++ {
+			var v string
+			if j < len(row) {
+				v = row[j]
+			}
+			w.cell("", func() { w.run(v, docxFormat{bold: header}) })
+		}
+		w.part.out.WriteString("</w:tr>")
+	}
+	if n.omitted > 0 {
+		more := fmt.Sprintf("%d more rows", n.omitted)
+		if n.omitted == 1 {
+			more = "1 more row"
+		}
+		w.rowStart(false)
+		fmt.Fprintf(&w.part.out, `<w:tc><w:tcPr><w:gridSpan w:val="%d"/></w:tcPr><w:p>`, max(columns, 1))
+		w.run(more, docxFormat{italic: true})
+		w.part.out.WriteString("</w:p></w:tc></w:tr>")
+	}
+	w.part.out.WriteString("</w:tbl><w:p/>")
+}
+
+func (w *docxWriter) tableStart(columns int) {
+	out := &w.part.out
+	out.WriteString(`<w:tbl><w:tblPr><w:tblStyle w:val="TableGrid"/><w:tblW w:w="0" w:type="auto"/></w:tblPr><w:tblGrid>`)
+	for i := 0; i < columns; i++ {
+		fmt.Fprintf(out, `<w:gridCol w:w="%d"/>`, 9360/max(columns, 1))
+	}
+	out.WriteString("</w:tblGrid>")
+}
+
+func (w *docxWriter) rowStart(header bool) {
+	if header {
+		w.part.out.WriteString("<w:tr><w:trPr><w:tblHeader/></w:trPr>")
+	} else {
+		w.part.out.WriteString("<w:tr>")
+	}
+}
+
+func (w *docxWriter) cell(align string, body func()) {
+	out := &w.part.out
+	out.WriteString("<w:tc><w:p>")
+	if align != "" {
+		out.WriteString(`<w:pPr><w:jc w:val="` + align + `"/></w:pPr>`)
+	}
+	body()
+	out.WriteString("</w:p></w:tc>")
+}
+
+// docxBookmark turns a heading ID into a bookmark name, which may not contain spaces nor exceed 40
+// characters
+func docxBookmark(id string) string {
+	id = strings.ReplaceAll(id, " ", "_")
+	if len(id) > 40 {
+		id = id[:40]
+	}
+	return id
+}
+
+const docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Default Extension="png" ContentType="image/png"/>` +
+	`<Default Extension="jpg" ContentType="image/jpeg"/>` +
+	`<Default Extension="jpeg" ContentType="image/jpeg"/>` +
+	`<Default Extension="gif" ContentType="image/gif"/>` +
+	`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+	`<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>` +
+	`<Override PartName="/word/numbering.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"/>` +
+	`<Override PartName="/word/footnotes.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.footnotes+xml"/>` +
+	`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+	`</Types>`
+
+// docxStyles are the styles paragraphs and runs refer to; headings use Word's built-in names so that
+// the navigation pane and tables of contents pick them up
+var docxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+	`<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="Calibri" w:hAnsi="Calibri" w:eastAsia="Calibri" w:cs="Calibri"/><w:sz w:val="22"/><w:szCs w:val="22"/><w:lang w:val="en-US"/></w:rPr></w:rPrDefault>` +
+	`<w:pPrDefault><w:pPr><w:spacing w:after="160" w:line="259" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>` +
+	`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/></w:style>` +
+	docxHeadingStyles() +
+	`<w:style w:type="paragraph" w:styleId="Quote"><w:name w:val="Quote"/><w:basedOn w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:pBdr><w:left w:val="single" w:sz="18" w:space="8" w:color="D0D7DE"/></w:pBdr><w:ind w:left="360"/></w:pPr><w:rPr><w:color w:val="59636E"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="SourceCode"><w:name w:val="Source Code"/><w:basedOn w:val="Normal"/>` +
+	`<w:pPr><w:shd w:val="clear" w:color="auto" w:fill="F6F8FA"/><w:spacing w:after="160" w:line="240" w:lineRule="auto"/></w:pPr>` +
+	`<w:rPr><w:rFonts w:ascii="Consolas" w:hAnsi="Consolas" w:cs="Consolas"/><w:sz w:val="19"/><w:szCs w:val="19"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="ListParagraph"><w:name w:val="List Paragraph"/><w:basedOn w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:spacing w:after="60"/><w:ind w:left="720"/></w:pPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Caption"><w:name w:val="caption"/><w:basedOn w:val="Normal"/><w:qFormat/>` +
+	`<w:rPr><w:i/><w:color w:val="59636E"/><w:sz w:val="18"/><w:szCs w:val="18"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="FootnoteText"><w:name w:val="footnote text"/><w:basedOn w:val="Normal"/>` +
+	`<w:pPr><w:spacing w:after="0" w:line="240" w:lineRule="auto"/></w:pPr><w:rPr><w:sz w:val="20"/><w:szCs w:val="20"/></w:rPr></w:style>` +
+	`<w:style w:type="character" w:styleId="FootnoteReference"><w:name w:val="footnote reference"/><w:rPr><w:vertAlign w:val="superscript"/></w:rPr></w:style>` +
+	`<w:style w:type="character" w:styleId="VerbatimChar"><w:name w:val="Verbatim Char"/>` +
+	`<w:rPr><w:rFonts w:ascii="Consolas" w:hAnsi="Consolas" w:cs="Consolas"/><w:sz w:val="19"/><w:szCs w:val="19"/><w:shd w:val="clear" w:color="auto" w:fill="EFF1F3"/></w:rPr></w:style>` +
+	`<w:style w:type="character" w:styleId="Hyperlink"><w:name w:val="Hyperlink"/><w:rPr><w:color w:val="0969DA"/><w:u w:val="single"/></w:rPr></w:style>` +
+	`<w:style w:type="table" w:styleId="TableGrid"><w:name w:val="Table Grid"/><w:tblPr><w:tblBorders>` +
+	`<w:top w:val="single" w:sz="4" w:space="0" w:color="D0D7DE"/><w:left w:val="single" w:sz="4" w:space="0" w:color="D0D7DE"/>` +
+	`<w:bottom w:val="single" w:sz="4" w:space="0" w:color="D0D7DE"/><w:right w:val="single" w:sz="4" w:space="0" w:color="D0D7DE"/>` +
+	`<w:insideH w:val="single" w:sz="4" w:space="0" w:color="D0D7DE"/><w:insideV w:val="single" w:sz="4" w:space="0" w:color="D0D7DE"/>` +
+	`</w:tblBorders><w:tblCellMar><w:left w:w="108" w:type="dxa"/><w:right w:w="108" w:type="dxa"/></w:tblCellMar></w:tblPr>` +
+	`<w:pPr><w:spacing w:after="0"/></w:pPr></w:style>` +
+	`</w:styles>`
+
+func docxHeadingStyles() string {
+	var b strings.Builder
+	sizes := []int{32, 28, 26, 24, 22, 22}
+	for i, size := range sizes {
+		level := i + 1
+		fmt.Fprintf(&b, `<w:style w:type="paragraph" w:styleId="Heading%d"><w:name w:val="heading %d"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>`+
+			`<w:pPr><w:keepNext/><w:spacing w:before="240" w:after="120"/><w:outlineLvl w:val="%d"/></w:pPr>`+
+			`<w:rPr><w:b/><w:sz w:val="%d"/><w:szCs w:val="%d"/></w:rPr></w:style>`, level, level, i, size, size)
+	}
+	return b.String()
+}
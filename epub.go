@@ -0,0 +1,338 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/yuin/goldmark/ast"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+)
+
+// media types of images an EPUB may carry
+var epubImageTypes = map[string]string{
+	".gif": "image/gif", ".jpeg": "image/jpeg", ".jpg": "image/jpeg", ".png": "image/png",
+	".svg": "image/svg+xml", ".webp": "image/webp",
+}
+
+// namedEntityRe matches named character references, which XHTML knows only 5 of
+var namedEntityRe = regexp.MustCompile(`&([a-zA-Z][a-zA-Z0-9]*);`)
+
+// epubChapter is a doc rendered as a content document of the book
+type epubChapter struct {
+	file, title string
+	body        []byte
+	toc         []epubTOCEntry
+}
+
+type epubTOCEntry struct {
+	level       int
+	title, href string
+}
+
+// epubResource is any other file of the book, e.g. an image
+type epubResource struct {
+	ID, File, MediaType string
+	data                []byte
+}
+
+// renderEPUB packages docs as chapters of an EPUB 3 book, in given order. Metadata comes from front
+// matter of the first doc: title, author, lang, date, description and publisher.
+func renderEPUB(w io.Writer, docs []*document) error {
+	// chapter files of the input docs, for links between them
+	chapterFiles := map[string]string{}
+	for i, doc := range docs {
+		if abs, err := filepath.Abs(doc.path); err == nil {
+			chapterFiles[abs] = fmt.Sprintf("chapter-%d.xhtml", i+1)
+		}
+	}
+	var chapters []epubChapter
+	var images []epubResource
+	// image files added to the book already, by absolute path
+	imageFiles := map[string]string{}
+	digest := sha1.New()
+	for i, doc := range docs {
+		digest.Write(doc.source)
+		root := doc.md.Parser().Parse(text.NewReader(doc.source))
+		ch := epubChapter{file: fmt.Sprintf("chapter-%d.xhtml", i+1), title: doc.title(root)}
+		if ch.title == "" {
+			ch.title = strings.TrimSuffix(filepath.Base(doc.path), filepath.Ext(doc.path))
+		}
+		dir := inputDir(doc.path)
+		headings := 0
+		ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			if !entering {
+				return ast.WalkContinue, nil
+			}
+			switch n := n.(type) {
+			case *ast.Heading:
+				id, ok := n.AttributeString("id")
+				if !ok {
+					headings++
+					id = []byte(fmt.Sprintf("heading-%d", headings))
+					n.SetAttributeString("id", id)
+				}
+				ch.toc = append(ch.toc, epubTOCEntry{level: n.Level, title: plainText(n, doc.source), href: ch.file + "#" + string(id.([]byte))})
+			case *ast.Image:
+				path, ok := localPath(string(n.Destination), dir)
+				mediaType := epubImageTypes[strings.ToLower(filepath.Ext(path))]
+				if !ok || mediaType == "" {
+					return ast.WalkContinue, nil
+				}
+				abs, _ := filepath.Abs(path)
+				file, added := imageFiles[abs]
+				if !added {
+					data, err := os.ReadFile(path)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "warning: image %s left out of the book: %v\n", n.Destination, err)
+						return ast.WalkContinue, nil
+					}
+					file = fmt.Sprintf("images/image-%d%s", len(images)+1, strings.ToLower(filepath.Ext(path)))
+					images = append(images, epubResource{ID: fmt.Sprintf("image-%d", len(images)+1), File: file, MediaType: mediaType, data: data})
+					imageFiles[abs] = file
+				}
+				n.Destination = []byte(file)
+			case *ast.Link:
+				// links to other docs of the book point to their chapters
+				dest, fragment, _ := strings.Cut(string(n.Destination), "#")
+				path, ok := localPath(dest, dir)
+				if !ok || dest == "" {
+					return ast.WalkContinue, nil
+				}
+				abs, _ := filepath.Abs(path)
+				if file, ok := chapterFiles[abs]; ok {
+					if fragment != "" {
+						file += "#" + fragment
+					}
+					n.Destination = []byte(file)
+				}
+			}
+			return ast.WalkContinue, nil
+		})
+		// content documents must be well-formed XML
+		doc.md.Renderer().AddOptions(gmhtml.WithXHTML())
+		var body bytes.Buffer
+		if err := doc.md.Renderer().Render(&body, doc.source, root); err != nil {
+			return fmt.Errorf("error rendering %s: %w", doc.path, err)
+		}
+		ch.body = namedEntityRe.ReplaceAllFunc(body.Bytes(), func(ref []byte) []byte {
+			switch string(ref) {
+			case "&amp;", "&lt;", "&gt;", "&quot;", "&apos;":
+				return ref
+			}
+			r := []rune(html.UnescapeString(string(ref)))
+			if len(r) != 1 {
+				return ref
+			}
+			return []byte(fmt.Sprintf("&#%d;", r[0]))
+		})
+		chapters = append(chapters, ch)
+	}
+
+	first := docs[0]
+	// a name based UUID, so that the book keeps its identifier as long as its contents stay the same
+	id := digest.Sum(nil)[:16]
+	id[6] = id[6]&0x0f | 0x50
+	id[8] = id[8]&0x3f | 0x80
+	book := epubBook{
+		ID:          fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16]),
+		Title:       first.meta["title"],
+		Author:      first.meta["author"],
+		Language:    first.meta["lang"],
+		Date:        first.meta["date"],
+		Description: first.meta["description"],
+		Publisher:   first.meta["publisher"],
+		Modified:    time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		Images:      images,
+	}
+	if book.Title == "" {
+		book.Title = chapters[0].title
+	}
+	if book.Language == "" {
+		book.Language = "en"
+	}
+	for _, ch := range chapters {
+		book.Chapters = append(book.Chapters, epubChapterData{File: ch.file, ID: strings.TrimSuffix(ch.file, ".xhtml"), Title: ch.title})
+	}
+	book.TOC = epubTOC(chapters)
+
+	zw := zip.NewWriter(w)
+	// the mimetype comes first and uncompressed so that the file can be identified by its head
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := mw.Write([]byte("application/epub+zip")); err != nil {
+		return err
+	}
+	add := func(name string, data []byte) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		return err
+	}
+	addTemplate := func(name string, tmpl *template.Template, data any) error {
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, data); err != nil {
+			return fmt.Errorf("error writing %s: %w", name, err)
+		}
+		return add(name, b.Bytes())
+	}
+	if err := add("META-INF/container.xml", []byte(epubContainerXML)); err != nil {
+		return err
+	}
+	if err := addTemplate("OEBPS/content.opf", epubOPFTemplate, book); err != nil {
+		return err
+	}
+	if err := addTemplate("OEBPS/nav.xhtml", epubNavTemplate, book); err != nil {
+		return err
+	}
+	if err := add("OEBPS/style.css", []byte(markDownStyleGithubCSS+codeBlockCSS)); err != nil {
+		return err
+	}
+	for _, ch := range chapters {
+		data := struct {
+			Title, Language, Body string
+		}{ch.title, book.Language, string(ch.body)}
+		if err := addTemplate("OEBPS/"+ch.file, epubChapterTemplate, data); err != nil {
+			return err
+		}
+	}
+	for _, img := range images {
+		if err := add("OEBPS/"+img.File, img.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// epubTOC renders the headings of chapters as nested list items of the nav document; chapters w/o
+// headings are listed by title
+func epubTOC(chapters []epubChapter) string {
+	var b strings.Builder
+	// levels of the lists being open
+	var open []int
+	closeTo := func(depth int) {
+		for len(open) > depth {
+			b.WriteString("</li>\n</ol>\n")
+			open = open[:len(open)-1]
+		}
+	}
+	for _, ch := range chapters {
+		toc := ch.toc
+		if len(toc) == 0 {
+			toc = []epubTOCEntry{{level: 1, title: ch.title, href: ch.file}}
+		}
+		for _, e := range toc {
+			// an entry nests in the closest one above of a lower level; the outermost list is the only
+			// one at the top of the nav though
+			for len(open) > 1 && e.level <= open[len(open)-2] {
+				closeTo(len(open) - 1)
+			}
+			switch {
+			case len(open) == 0 || e.level > open[len(open)-1]:
+				b.WriteString("<ol>\n")
+				open = append(open, e.level)
+			default:
+				b.WriteString("</li>\n")
+				open[len(open)-1] = e.level
+			}
+			title := e.title
+			if title == "" {
+				title = ch.title
+			}
+			fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a>\n", html.EscapeString(e.href), html.EscapeString(title))
+		}
+	}
+	closeTo(0)
+	return b.String()
+}
+
+// epubBook is the data of package and navigation documents
+type epubBook struct {
+	ID, Title, Author, Language, Date, Description, Publisher, Modified string
+	Chapters                                                            []epubChapterData
+	Images                                                              []epubResource
+	// nested list items of the table of contents
+	TOC string
+}
+
+type epubChapterData struct {
+	File, ID, Title string
+}
+
+const epubContainerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles>
+<rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+</rootfiles>
+</container>
+`
+
+var epubFuncs = template.FuncMap{"xml": html.EscapeString}
+
+var epubOPFTemplate = template.Must(template.New("opf").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="{{xml .Language}}">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="book-id">{{xml .ID}}</dc:identifier>
+<dc:title>{{xml .Title}}</dc:title>
+<dc:language>{{xml .Language}}</dc:language>
+{{if .Author}}<dc:creator>{{xml .Author}}</dc:creator>
+{{end}}{{if .Date}}<dc:date>{{xml .Date}}</dc:date>
+{{end}}{{if .Description}}<dc:description>{{xml .Description}}</dc:description>
+{{end}}{{if .Publisher}}<dc:publisher>{{xml .Publisher}}</dc:publisher>
+{{end}}<meta property="dcterms:modified">{{.Modified}}</meta>
+</metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+<item id="style" href="style.css" media-type="text/css"/>
+{{range .Chapters}}<item id="{{.ID}}" href="{{.File}}" media-type="application/xhtml+xml"/>
+{{end}}{{range .Images}}<item id="{{.ID}}" href="{{.File}}" media-type="{{.MediaType}}"/>
+{{end}}</manifest>
+<spine>
+{{range .Chapters}}<itemref idref="{{.ID}}"/>
+{{end}}</spine>
+</package>
+`))
+
+var epubNavTemplate = template.Must(template.New("nav").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{xml .Language}}" lang="{{xml .Language}}">
+<head>
+<meta charset="UTF-8"/>
+<title>{{xml .Title}}</title>
+</head>
+<body>
+<nav epub:type="toc" id="toc">
+<h1>{{xml .Title}}</h1>
+{{.TOC}}</nav>
+</body>
+</html>
+`))
+
+var epubChapterTemplate = template.Must(template.New("chapter").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{xml .Language}}" lang="{{xml .Language}}">
+<head>
+<meta charset="UTF-8"/>
+<title>{{xml .Title}}</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+<article class="markdown-body">
+{{.Body}}</article>
+</body>
+</html>
+`))
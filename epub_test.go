@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEPUBChaptersAreXML(t *testing.T) {
+	md := "# Chapter\n\nLine<br>break <img src=\"a.png\" alt=\"a\"> &nbsp; <details open>x</details>\n\n<p>para<br></p>\n\n---\n"
+	dir := t.TempDir()
+	path := filepath.Join(dir, "doc.md")
+	if err := os.WriteFile(path, []byte(md), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range []string{htmlOmit, htmlSanitize} {
+		t.Run(mode, func(t *testing.T) {
+			doc, err := loadDocument(path, markdownOptions{html: mode}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := renderEPUB(&out, []*document{doc}); err != nil {
+				t.Fatal(err)
+			}
+			chapter := zipPart(t, out.Bytes(), "OEBPS/chapter-1.xhtml")
+			d := xml.NewDecoder(bytes.NewReader(chapter))
+			for {
+				if _, err := d.Token(); errors.Is(err, io.EOF) {
+					break
+				} else if err != nil {
+					t.Fatalf("chapter is not well-formed XML: %v\n%s", err, chapter)
+				}
+			}
+		})
+	}
+}
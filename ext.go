@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// markdownExtension is an optional piece of Markdown syntax or rendering behavior, switched on and off
+// by name
+type markdownExtension struct {
+	usage string
+	// options returns what to configure goldmark w/ when the extension is enabled
+	options func(cfg *config, opts markdownOptions) []goldmark.Option
+}
+
+func extenders(e ...goldmark.Extender) func(*config, markdownOptions) []goldmark.Option {
+	return func(*config, markdownOptions) []goldmark.Option {
+		return []goldmark.Option{goldmark.WithExtensions(e...)}
+	}
+}
+
+var markdownExtensions = map[string]markdownExtension{
+	"table": {
+		usage:   "GFM tables",
+		options: extenders(extension.Table),
+	},
+	"strikethrough": {
+		usage:   "GFM ~~strikethrough~~",
+		options: extenders(extension.Strikethrough),
+	},
+	"linkify": {
+		usage: "GFM autolinks of bare URLs, www. links and emails",
+		options: func(cfg *config, _ markdownOptions) []goldmark.Option {
+			var opts []extension.LinkifyOption
+			if len(cfg.Linkify.Protocols) > 0 {
+				opts = append(opts, extension.WithLinkifyAllowedProtocols(cfg.Linkify.Protocols))
+			}
+			return []goldmark.Option{goldmark.WithExtensions(extension.NewLinkify(opts...))}
+		},
+	},
+	"tasklist": {
+		usage:   "GFM task list items `- [x] done`",
+		options: extenders(extension.TaskList),
+	},
+	"footnote": {
+		usage:   "footnotes `[^1]`",
+		options: extenders(extension.Footnote),
+	},
+	"deflist": {
+		usage:   "definition lists `term\\n: definition`",
+		options: extenders(extension.DefinitionList),
+	},
+	"typographer": {
+		usage:   "smart quotes, dashes and ellipses",
+		options: extenders(extension.Typographer),
+	},
+	"cjk": {
+		usage:   "CJK friendly line breaks and emphasis",
+		options: extenders(extension.CJK),
+	},
+	"attribute": {
+		usage: "custom heading attributes `{#id .class}`",
+		options: func(*config, markdownOptions) []goldmark.Option {
+			return []goldmark.Option{goldmark.WithParserOptions(parser.WithAttribute())}
+		},
+	},
+	"autoheadingid": {
+		usage: "generated heading IDs",
+		options: func(*config, markdownOptions) []goldmark.Option {
+			return []goldmark.Option{goldmark.WithParserOptions(parser.WithAutoHeadingID())}
+		},
+	},
+	"mark": {
+		usage:   "==highlight== as <mark>",
+		options: extenders(markSyntax),
+	},
+	"sub": {
+		usage:   "H~2~O subscripts as <sub>",
+		options: extenders(subSyntax),
+	},
+	"sup": {
+		usage:   "x^2^ superscripts as <sup>",
+		options: extenders(supSyntax),
+	},
+	"kbd": {
+		usage:   "++Ctrl++ keys as <kbd>",
+		options: extenders(kbdSyntax),
+	},
+	"math": {
+		usage:   "TeX math `$x^2$`, `$$x^2$$` and fenced `$$` blocks, as MathJax and KaTeX pick it up",
+		options: extenders(mathSyntax{}),
+	},
+	"container": {
+		usage:   "fenced containers `::: details \"Click to expand\"` or `::: warning Deprecated`",
+		options: extenders(containerSyntax{}),
+	},
+	"codemeta": {
+		usage:   "code block titles, line numbers and highlighted lines ```go title=\"main.go\" {3,7-9} linenos",
+		options: extenders(codeMetaSyntax{}),
+	},
+	"figure": {
+		usage: "standalone images as <figure> captioned by their title, `{width=400}` image attributes and lazily loaded, sized local images",
+		options: func(_ *config, opts markdownOptions) []goldmark.Option {
+			return []goldmark.Option{goldmark.WithExtensions(figureSyntax{docDir: opts.docDir})}
+		},
+	},
+	"csv": {
+		usage: "fenced ```csv and ```tsv blocks and standalone `![table](data.csv)` references as tables",
+		options: func(_ *config, opts markdownOptions) []goldmark.Option {
+			return []goldmark.Option{goldmark.WithExtensions(newCSVSyntax(opts.docDir))}
+		},
+	},
+	"wikilink": {
+		usage: "wiki links `[[Page Name]]` and `[[Page Name|label]]` resolved within a docs folder",
+		options: func(cfg *config, opts markdownOptions) []goldmark.Option {
+			return []goldmark.Option{goldmark.WithExtensions(newWikiLinkSyntax(cfg, opts.docDir))}
+		},
+	},
+	"autoref": {
+		usage: "links of references like #123 or PROJ-456 per the autolinks rules of config; on when there are any",
+		options: func(cfg *config, _ markdownOptions) []goldmark.Option {
+			return []goldmark.Option{goldmark.WithExtensions(newAutorefSyntax(cfg))}
+		},
+	},
+	"hardwraps": {
+		usage: "render every newline in paragraphs as a line break",
+		options: func(*config, markdownOptions) []goldmark.Option {
+			return []goldmark.Option{goldmark.WithRendererOptions(html.WithHardWraps())}
+		},
+	},
+}
+
+// GFM as goldmark has it
+var gfmExtensions = []string{"table", "strikethrough", "linkify", "tasklist"}
+
+func extensionNames() []string {
+	var names []string
+	for name := range markdownExtensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveExtensions returns the sorted names of extensions enabled by given profile, adjusted by
+// the extension lists of config and then flag. Items look like `+deflist` or `-strikethrough`; a name
+// w/o sign enables the extension.
+func resolveExtensions(profileName string, cfg *config, extFlag string) ([]string, error) {
+	p, ok := profiles[profileName]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q", profileName)
+	}
+	enabled := map[string]bool{}
+	for _, name := range p.extensions {
+		enabled[name] = true
+	}
+	// configuring rules is enough to get references linked
+	if len(cfg.Autolinks) > 0 {
+		enabled["autoref"] = true
+	}
+	var items []string
+	items = append(items, cfg.Extensions...)
+	if extFlag != "" {
+		items = append(items, strings.Split(extFlag, ",")...)
+	}
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		on := !strings.HasPrefix(item, "-")
+		name := strings.TrimLeft(item, "+-")
+		if name == "" {
+			continue
+		}
+		if _, ok := markdownExtensions[name]; !ok {
+			return nil, fmt.Errorf("unknown extension %q, expect one of %s", name, strings.Join(extensionNames(), ", "))
+		}
+		enabled[name] = on
+	}
+	var names []string
+	for name, on := range enabled {
+		if on {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
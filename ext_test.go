@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveExtensions(t *testing.T) {
+	tests := []struct {
+		name, profile string
+		cfg           []string
+		flag          string
+		want          []string
+		wantErr       bool
+	}{
+		{name: "profile", profile: "gfm-doc", want: []string{"linkify", "strikethrough", "table", "tasklist"}},
+		{name: "config", profile: "commonmark", cfg: []string{"+deflist", "typographer"}, want: []string{"deflist", "typographer"}},
+		{name: "config off", profile: "gfm-doc", cfg: []string{"-strikethrough", "-linkify"}, want: []string{"table", "tasklist"}},
+		{name: "flag over config", profile: "commonmark", cfg: []string{"+deflist", "-typographer"}, flag: "-deflist, +typographer", want: []string{"typographer"}},
+		{name: "empty items", profile: "commonmark", flag: ",+cjk,", want: []string{"cjk"}},
+		{name: "unknown extension", profile: "commonmark", flag: "+nope", wantErr: true},
+		{name: "unknown profile", profile: "nope", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveExtensions(tt.profile, &config{Extensions: tt.cfg}, tt.flag)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("want an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtensions(t *testing.T) {
+	tests := []struct {
+		name, ext, md, want string
+		protocols           []string
+	}{
+		{
+			name: "deflist",
+			ext:  "+deflist",
+			md:   "term\n: def",
+			want: "<dl>\n<dt>term</dt>\n<dd>def</dd>\n</dl>",
+		},
+		{
+			name: "typographer",
+			ext:  "+typographer",
+			md:   `"a" -- b...`,
+			want: "<p>&ldquo;a&rdquo; &ndash; b&hellip;</p>",
+		},
+		{
+			name: "heading attributes and ids",
+			ext:  "+attribute,+autoheadingid",
+			md:   "# One {.big}\n\n# Two Words",
+			want: "<h1 class=\"big\" id=\"one\">One</h1>\n<h1 id=\"two-words\">Two Words</h1>",
+		},
+		{
+			name:      "linkify protocols",
+			md:        "see https://a.example and ftp://b.example",
+			protocols: []string{"ftp:"},
+			want:      `<p>see https://a.example and <a href="ftp://b.example">ftp://b.example</a></p>`,
+		},
+		{
+			name: "off",
+			ext:  "-strikethrough",
+			md:   "~~a~~",
+			want: "<p>~~a~~</p>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config{}
+			cfg.Linkify.Protocols = tt.protocols
+			md, err := markdownFor(markdownOptions{profile: "gfm-doc", ext: tt.ext}, nil, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := md.Convert([]byte(tt.md), &out); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(out.String()); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"html"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	kindFigure     = ast.NewNodeKind("Figure")
+	kindImageAttrs = ast.NewNodeKind("ImageAttributes")
+)
+
+// attributes an attribute list may set on images; others, e.g. event handlers, styles or URLs, would
+// get around the allowlist of the sanitize mode and are ignored
+var imageAttrNames = map[string]bool{"width": true, "height": true, "class": true, "id": true}
+
+// figureBlock is a paragraph holding nothing but an image, optionally linked; the image title is its
+// caption
+type figureBlock struct {
+	ast.BaseBlock
+	caption []byte
+}
+
+func (n *figureBlock) Kind() ast.NodeKind {
+	return kindFigure
+}
+
+func (n *figureBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Caption": string(n.caption)}, nil)
+}
+
+// imageAttrs is an attribute list like `{width=400 .rounded}` directly following an image; it is
+// applied to the image and renders as nothing itself
+type imageAttrs struct {
+	ast.BaseInline
+	// the list as written, braces included
+	raw string
+}
+
+func (n *imageAttrs) Kind() ast.NodeKind {
+	return kindImageAttrs
+}
+
+func (n *imageAttrs) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Raw": n.raw}, nil)
+}
+
+// figureSyntax is the goldmark extension turning standalone images into figures and sizing images;
+// local images resolve relative to docDir
+type figureSyntax struct {
+	docDir string
+}
+
+func (s figureSyntax) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(s, 400)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(s, 500)))
+}
+
+func (s figureSyntax) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	var images []*ast.Image
+	var paragraphs []*ast.Paragraph
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Image:
+			images = append(images, n)
+			return ast.WalkSkipChildren, nil
+		case *ast.Paragraph:
+			paragraphs = append(paragraphs, n)
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, img := range images {
+		if t, ok := img.NextSibling().(*ast.Text); ok {
+			takeImageAttrs(img, t, source, imageAttrNames)
+		}
+		img.SetAttributeString("loading", []byte("lazy"))
+		s.setDimensions(img)
+	}
+	for _, p := range paragraphs {
+		img := standaloneImage(p, source)
+		if img == nil {
+			continue
+		}
+		fig := &figureBlock{caption: img.Title}
+		fig.SetLines(p.Lines())
+		for c := p.FirstChild(); c != nil; {
+			next := c.NextSibling()
+			fig.AppendChild(fig, c)
+			c = next
+		}
+		p.Parent().ReplaceChild(p.Parent(), p, fig)
+	}
+}
+
+// takeImageAttrs moves an attribute list at the start of t, which follows img, into an imageAttrs node;
+// names not in names are dropped. The list is read from source, as inline parsers may have split it across adjacent text nodes and
+// the typographer may have replaced its quotes w/ string nodes.
+func takeImageAttrs(img *ast.Image, t *ast.Text, source []byte, names map[string]bool) {
+	if v := t.Segment.Value(source); len(v) == 0 || v[0] != '{' {
+		return
+	}
+	// the list ends at the first `}` outside quotes on the same line
+	end := -1
+	var quote byte
+	for i := t.Segment.Start + 1; i < len(source) && end < 0 && source[i] != '\n'; i++ {
+		switch c := source[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '}':
+			end = i + 1
+		}
+	}
+	if end < 0 {
+		return
+	}
+	// the nodes covering the list: text w/in it and typographic strings in between, the last text
+	// holding its closing brace
+	var covered []ast.Node
+	var last *ast.Text
+	for n := ast.Node(t); last == nil; n = n.NextSibling() {
+		switch n := n.(type) {
+		case *ast.Text:
+			if n.Segment.Stop >= end {
+				last = n
+			} else if n.SoftLineBreak() || n.HardLineBreak() {
+				return
+			}
+		case *ast.String:
+		default:
+			return
+		}
+		covered = append(covered, n)
+	}
+	raw := string(source[t.Segment.Start:end])
+	type attr struct{ name, value string }
+	var attrs []attr
+	for _, field := range splitQuoted(raw[1 : len(raw)-1]) {
+		switch {
+		case strings.HasPrefix(field, "."):
+			attrs = append(attrs, attr{"class", field[1:]})
+		case strings.HasPrefix(field, "#"):
+			attrs = append(attrs, attr{"id", field[1:]})
+		default:
+			k, v, ok := strings.Cut(field, "=")
+			if !ok || k == "" {
+				// not an attribute list after all
+				return
+			}
+			if names[strings.ToLower(k)] {
+				attrs = append(attrs, attr{strings.ToLower(k), unquote(v)})
+			}
+		}
+	}
+	for _, a := range attrs {
+		if prev, ok := img.AttributeString(a.name); ok && a.name == "class" {
+			a.value = string(prev.([]byte)) + " " + a.value
+		}
+		img.SetAttributeString(a.name, []byte(a.value))
+	}
+	parent := t.Parent()
+	parent.InsertBefore(parent, t, &imageAttrs{raw: raw})
+	for _, c := range covered[:len(covered)-1] {
+		parent.RemoveChild(parent, c)
+	}
+	if end == last.Segment.Stop && !last.SoftLineBreak() && !last.HardLineBreak() {
+		parent.RemoveChild(parent, last)
+		return
+	}
+	last.Segment = last.Segment.WithStart(end)
+}
+
+// setDimensions sets width and height of a local PNG, JPEG or GIF image so that the page layout does
+// not shift once it loads; a size given by attributes is kept and the other side scaled to match
+func (s figureSyntax) setDimensions(img *ast.Image) {
+	path, ok := localPath(string(img.Destination), s.docDir)
+	if !ok {
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	c, _, err := image.DecodeConfig(f)
+	if err != nil || c.Width == 0 || c.Height == 0 {
+		return
+	}
+	width, hasWidth := img.AttributeString("width")
+	height, hasHeight := img.AttributeString("height")
+	switch {
+	case hasWidth && hasHeight:
+	case hasWidth:
+		if w, err := strconv.Atoi(string(width.([]byte))); err == nil {
+			img.SetAttributeString("height", []byte(strconv.Itoa(w*c.Height/c.Width)))
+		}
+	case hasHeight:
+		if h, err := strconv.Atoi(string(height.([]byte))); err == nil {
+			img.SetAttributeString("width", []byte(strconv.Itoa(h*c.Width/c.Height)))
+		}
+	default:
+		img.SetAttributeString("width", []byte(strconv.Itoa(c.Width)))
+		img.SetAttributeString("height", []byte(strconv.Itoa(c.Height)))
+	}
+}
+
+// localPath returns the file a relative link destination refers to, resolved against dir; URLs and
+// absolute paths are not local
+func localPath(dest, dir string) (string, bool) {
+	if dest == "" || strings.Contains(dest, ":") || strings.HasPrefix(dest, "/") {
+		return "", false
+	}
+	dest, _, _ = strings.Cut(dest, "?")
+	dest, _, _ = strings.Cut(dest, "#")
+	if p, err := url.PathUnescape(dest); err == nil {
+		dest = p
+	}
+	return filepath.Join(dir, filepath.FromSlash(dest)), true
+}
+
+// standaloneImage returns the image if it is the only content of p besides its attribute list; the
+// image may be wrapped in a link
+func standaloneImage(p *ast.Paragraph, source []byte) *ast.Image {
+	var found ast.Node
+	for c := p.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c := c.(type) {
+		case *imageAttrs:
+		case *ast.Text:
+			if len(strings.TrimSpace(string(c.Segment.Value(source)))) > 0 {
+				return nil
+			}
+		default:
+			if found != nil {
+				return nil
+			}
+			found = c
+		}
+	}
+	if link, ok := found.(*ast.Link); ok && link.ChildCount() == 1 {
+		found = link.FirstChild()
+	}
+	img, _ := found.(*ast.Image)
+	return img
+}
+
+func (s figureSyntax) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindFigure, renderFigure)
+	reg.Register(kindImageAttrs, func(util.BufWriter, []byte, ast.Node, bool) (ast.WalkStatus, error) {
+		return ast.WalkContinue, nil
+	})
+}
+
+func renderFigure(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*figureBlock)
+	if entering {
+		_, _ = w.WriteString("<figure>\n")
+		return ast.WalkContinue, nil
+	}
+	_, _ = w.WriteString("\n")
+	if len(n.caption) > 0 {
+		_, _ = w.WriteString("<figcaption>" + html.EscapeString(string(n.caption)) + "</figcaption>\n")
+	}
+	_, _ = w.WriteString("</figure>\n")
+	return ast.WalkContinue, nil
+}
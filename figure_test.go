@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestImageAttrs(t *testing.T) {
+	tests := []struct {
+		name, md, want string
+	}{
+		{
+			name: "typographer quotes",
+			md:   `![Pic](a.png){width=50% id="quoted" .wide} after "x"`,
+			want: `<p><img src="a.png" alt="Pic" width="50%" id="quoted" class="wide" loading="lazy"> after &ldquo;x&rdquo;</p>`,
+		},
+		{
+			name: "typographer dashes within quotes",
+			md:   `![Pic](a.png){class="a -- b..."}`,
+			want: "<figure>\n<img src=\"a.png\" alt=\"Pic\" class=\"a -- b...\" loading=\"lazy\">\n</figure>",
+		},
+		{
+			name: "brace within quotes",
+			md:   `![Pic](a.png){class="}" #pic}`,
+			want: "<figure>\n<img src=\"a.png\" alt=\"Pic\" class=\"}\" id=\"pic\" loading=\"lazy\">\n</figure>",
+		},
+		{
+			name: "list followed by a line break",
+			md:   "![Pic](a.png){.a}\nnext",
+			want: "<p><img src=\"a.png\" alt=\"Pic\" class=\"a\" loading=\"lazy\">\nnext</p>",
+		},
+		{
+			name: "unclosed list",
+			md:   "![Pic](a.png){width=1\n}",
+			want: "<p><img src=\"a.png\" alt=\"Pic\" loading=\"lazy\">{width=1\n}</p>",
+		},
+		{
+			name: "not an attribute list",
+			md:   `![Pic](a.png){see "below"}`,
+			want: `<p><img src="a.png" alt="Pic" loading="lazy">{see &ldquo;below&rdquo;}</p>`,
+		},
+	}
+	md, err := markdownFor(markdownOptions{profile: "rmd-extended"}, nil, &config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := md.Convert([]byte(tt.md), &out); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(out.String()); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
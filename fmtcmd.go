@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// fmtMain implements `rmd fmt`, which rewrites Markdown docs in canonical form much like gofmt does
+// to Go code. W/o file arguments it formats stdin to stdout.
+func fmtMain(args []string) {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "Write result to (source) file instead of stdout")
+	showDiff := fs.Bool("d", false, "Display diffs instead of rewriting files")
+	wrap := fs.Int("wrap", -1, "Paragraph line breaks: -1 keeps them as written, 0 unwraps paragraphs, N > 0 wraps at N columns")
+	profileName := fs.String("profile", "", "Markdown dialect profile to parse docs w/ (default from front matter or config, else "+defaultProfile+")")
+	extFlag := fs.String("ext", "", "Comma separated extensions to enable (+name) or disable (-name) on top of the profile")
+	configPath := fs.String("config", "", "Config file path (default "+configFileName+" found from the doc's directory upwards)")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		if *write {
+			panic(errors.New("error formatting standard input: cannot use -w w/o file arguments"))
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			panic(fmt.Errorf("error reading all Markdown content from input: %w", err))
+		}
+		out := formatMarkdown(src, *wrap, *profileName, *extFlag, *configPath, ".")
+		if *showDiff {
+			out = unifiedDiff("<standard input>.orig", "<standard input>", src, out)
+		}
+		if _, err := os.Stdout.Write(out); err != nil {
+			panic(fmt.Errorf("error writing formatted output: %w", err))
+		}
+		return
+	}
+
+	for _, p := range fs.Args() {
+		src, err := os.ReadFile(p)
+		if err != nil {
+			panic(fmt.Errorf("error reading input file %s: %w", p, err))
+		}
+		out := formatMarkdown(src, *wrap, *profileName, *extFlag, *configPath, filepath.Dir(p))
+		if *showDiff {
+			if _, err := os.Stdout.Write(unifiedDiff(p+".orig", p, src, out)); err != nil {
+				panic(fmt.Errorf("error writing diff of %s: %w", p, err))
+			}
+		}
+		if *write {
+			if bytes.Equal(src, out) {
+				continue
+			}
+			fi, err := os.Stat(p)
+			if err != nil {
+				panic(fmt.Errorf("error reading input file %s: %w", p, err))
+			}
+			if err := os.WriteFile(p, out, fi.Mode().Perm()); err != nil {
+				panic(fmt.Errorf("error writing formatted output to %s: %w", p, err))
+			}
+		}
+		if !*showDiff && !*write {
+			if _, err := os.Stdout.Write(out); err != nil {
+				panic(fmt.Errorf("error writing formatted output: %w", err))
+			}
+		}
+	}
+}
+
+// formatMarkdown parses src the same way rendering does and returns its canonical form; front
+// matter is kept as is
+func formatMarkdown(src []byte, wrap int, profileName, extFlag, configPath, dir string) []byte {
+	meta, body := splitFrontMatter(src)
+	cfg, err := loadConfig(configPath, dir)
+	if err != nil {
+		panic(err)
+	}
+	// references must stay as written rather than turn into links
+	md, err := markdownFor(markdownOptions{profile: profileName, ext: extFlag + ",-autoref", docDir: dir}, meta, cfg)
+	if err != nil {
+		panic(err)
+	}
+	// link reference definitions are kept in place rather than dropped
+	md.Parser().AddOptions(parser.WithParagraphTransformers(
+		util.Prioritized(linkRefDefsTransformer{}, 99),
+		util.Prioritized(linkRefDefsTransformer{after: true}, 101),
+	))
+	doc := md.Parser().Parse(text.NewReader(body))
+	f := &markdownFormatter{source: body, wrap: wrap}
+	out := f.Format(doc)
+	if frontMatter := src[:len(src)-len(body)]; len(frontMatter) > 0 {
+		if len(out) > 0 {
+			// keep the body apart from front matter
+			out = append([]byte("\n"), out...)
+		}
+		out = append(append([]byte(nil), frontMatter...), out...)
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFormatMarkdown(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			name: "reference links and definitions",
+			in:   "See [a][x], [b] and [c][].\n\n[x]: https://x.example \"X\"\n[b]: <https://b.example>\n\n[c]: /c\n",
+			want: "See [a][x], [b] and [c][].\n\n[x]: https://x.example \"X\"\n[b]: <https://b.example>\n\n[c]: /c\n",
+		},
+		{
+			name: "definitions in front of a paragraph",
+			in:   "[x]: /x\ntext [*a*][x] and ![i][x]\n",
+			want: "[x]: /x\n\ntext [*a*][x] and ![i][x]\n",
+		},
+		{
+			name: "definitions in containers",
+			in:   "> [q]: /q\n> [q]\n\n- item\n\n  [d]: /d\n",
+			want: "> [q]: /q\n>\n> [q]\n\n- item\n\n  [d]: /d\n",
+		},
+		{
+			name: "tables and tight lists",
+			in:   "Intro:\n1. one\n2. two\n\n|a|b|\n|-|:-:|\n|1|2|\n",
+			want: "Intro:\n\n1. one\n2. two\n\n| a   |  b  |\n| --- | :-: |\n| 1   |  2  |\n",
+		},
+		{
+			name: "canonical markers",
+			in:   "Title\n=====\n\n* a\n* b\n\n__x__ _y_\n\n    code\n",
+			want: "# Title\n\n- a\n- b\n\n**x** *y*\n\n```\ncode\n```\n",
+		},
+		{
+			name: "front matter",
+			in:   "---\ntitle: T\n---\n[l](/l)\n",
+			want: "---\ntitle: T\n---\n\n[l](/l)\n",
+		},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(formatMarkdown([]byte(tt.in), -1, "", "", "", dir))
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			// formatting is idempotent
+			if again := string(formatMarkdown([]byte(got), -1, "", "", "", dir)); again != got {
+				t.Errorf("not idempotent, 2nd run:\n%s\n1st run:\n%s", again, got)
+			}
+		})
+	}
+}
+
+func TestFormatMarkdownKeepsRendering(t *testing.T) {
+	tests := []struct {
+		name, in string
+	}{
+		{name: "escaped quote in title", in: `[link](<a b> "q\"x")`},
+		{name: "quotes in single quoted title", in: `[b](/b 's "q" \'x')`},
+		{name: "quotes in parenthesized title", in: `[c](/c (p "q"))`},
+		{name: "trailing backslash in title", in: `[d](/d "e\\")`},
+		{name: "image title", in: `![i](/i.png "say \"cheese\"")`},
+		{name: "reference links", in: "[a][x] [b]\n\n[x]: /x \"t\\\"x\"\n[b]: </b c>\n"},
+		{name: "escapes and entities", in: "\\*not em\\* &amp; &copy; \\[x\\]"},
+	}
+	md, err := markdownFor(markdownOptions{}, nil, &config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	render := func(src string) string {
+		var out bytes.Buffer
+		if err := md.Convert([]byte(src), &out); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatted := string(formatMarkdown([]byte(tt.in), -1, "", "", "", dir))
+			if before, after := render(tt.in), render(formatted); before != after {
+				t.Errorf("fmt changed rendering of %q to %q:\n%s\nvs.\n%s", tt.in, formatted, before, after)
+			}
+		})
+	}
+}
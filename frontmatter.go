@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// splitFrontMatter separates YAML front matter delimited by `---` lines at the very start of src
+// from the Markdown body. Only flat `key: value` pairs are picked up; nested structures are skipped.
+// Blocks whose lines are not YAML of that shape are left in the body, as they are more likely
+// thematic breaks around a paragraph.
+func splitFrontMatter(src []byte) (meta map[string]string, body []byte) {
+	meta = map[string]string{}
+	rest, ok := cutLine(src, "---")
+	if !ok {
+		return meta, src
+	}
+	var lines []string
+	for len(rest) > 0 {
+		nl := bytes.IndexByte(rest, '\n')
+		if nl < 0 {
+			nl = len(rest)
+		} else {
+			nl++
+		}
+		line := strings.TrimRight(string(rest[:nl]), "\r\n")
+		rest = rest[nl:]
+		if line == "---" || line == "..." {
+			if !yamlMapping(lines) {
+				return meta, src
+			}
+			for _, l := range lines {
+				// indented lines belong to nested values
+				if l == "" || l[0] == ' ' || l[0] == '\t' || l[0] == '#' || l[0] == '-' {
+					continue
+				}
+				if k, v, ok := strings.Cut(l, ":"); ok {
+					meta[strings.TrimSpace(k)] = unquoteYAML(strings.TrimSpace(v))
+				}
+			}
+			return meta, rest
+		}
+		lines = append(lines, line)
+	}
+	// w/o a closing delimiter there is no front matter but a thematic break
+	return meta, src
+}
+
+// yamlKeyRe matches the start of `key: value` lines
+var yamlKeyRe = regexp.MustCompile(`^[^\s:#-][^:]*:(\s|$)`)
+
+// yamlMapping reports whether lines look like a YAML mapping: `key: value` lines, each maybe
+// followed by indented or `- ` list lines for nested values, plus blank and comment lines
+func yamlMapping(lines []string) bool {
+	key := false
+	for _, l := range lines {
+		switch {
+		case strings.TrimSpace(l) == "" || l[0] == '#':
+		case l[0] == ' ' || l[0] == '\t' || l == "-" || strings.HasPrefix(l, "- "):
+			if !key {
+				return false
+			}
+		case yamlKeyRe.MatchString(l):
+			key = true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// cutLine returns what follows the first line of src if that line equals line
+func cutLine(src []byte, line string) ([]byte, bool) {
+	nl := bytes.IndexByte(src, '\n')
+	if nl < 0 || strings.TrimRight(string(src[:nl]), "\r") != line {
+		return nil, false
+	}
+	return src[nl+1:], true
+}
+
+func unquoteYAML(v string) string {
+	if len(v) >= 2 && (v[0] == '"' && v[len(v)-1] == '"' || v[0] == '\'' && v[len(v)-1] == '\'') {
+		return unquote(`"` + strings.ReplaceAll(v[1:len(v)-1], `"`, `\"`) + `"`)
+	}
+	return v
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name, src, body string
+		meta            map[string]string
+	}{
+		{
+			name: "flat pairs",
+			src:  "---\ntitle: \"A: b\"\nauthor: 'Jo'\n---\nbody\n",
+			body: "body\n",
+			meta: map[string]string{"title": "A: b", "author": "Jo"},
+		},
+		{
+			name: "nested values and comments",
+			src:  "---\n# meta\ntags:\n- a\n- b\nextra:\n  x: 1\n\nprofile: gfm\n...\nbody\n",
+			body: "body\n",
+			meta: map[string]string{"tags": "", "extra": "", "profile": "gfm"},
+		},
+		{
+			name: "empty",
+			src:  "---\n---\nbody\n",
+			body: "body\n",
+			meta: map[string]string{},
+		},
+		{
+			name: "not yaml",
+			src:  "---\nnot yaml\n---\nbody\n",
+			body: "---\nnot yaml\n---\nbody\n",
+			meta: map[string]string{},
+		},
+		{
+			name: "sentence w/ a colon",
+			src:  "---\nNote:the rest\n---\nbody\n",
+			body: "---\nNote:the rest\n---\nbody\n",
+			meta: map[string]string{},
+		},
+		{
+			name: "list w/o a key",
+			src:  "---\n- a\n---\nbody\n",
+			body: "---\n- a\n---\nbody\n",
+			meta: map[string]string{},
+		},
+		{
+			name: "unclosed",
+			src:  "---\ntitle: a\nbody\n",
+			body: "---\ntitle: a\nbody\n",
+			meta: map[string]string{},
+		},
+		{
+			name: "not at the start",
+			src:  "\n---\ntitle: a\n---\n",
+			body: "\n---\ntitle: a\n---\n",
+			meta: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, body := splitFrontMatter([]byte(tt.src))
+			if string(body) != tt.body {
+				t.Errorf("body: got %q, want %q", body, tt.body)
+			}
+			if !reflect.DeepEqual(meta, tt.meta) {
+				t.Errorf("meta: got %v, want %v", meta, tt.meta)
+			}
+		})
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// renderGemtext converts the doc to gemtext, the line based format of the Gemini protocol. Gemtext has
+// no inline markup, so links go to link lines after their block and styling is dropped; constructs
+// losing information are warned about on stderr.
+func renderGemtext(w io.Writer, md goldmark.Markdown, doc *document) error {
+	root := md.Parser().Parse(text.NewReader(doc.source))
+	gw := &gemtextWriter{source: doc.source, footnotes: map[int]*east.Footnote{}, noteNums: map[int]int{}, warned: map[string]*gemtextWarning{}}
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if fn, ok := n.(*east.Footnote); ok && entering {
+			gw.footnotes[fn.Index] = fn
+		}
+		return ast.WalkContinue, nil
+	})
+	lines := gw.blocks(root)
+	if len(gw.notes) > 0 {
+		lines = append(lines, "")
+		for i, note := range gw.notes {
+			marker := "[" + strconv.Itoa(i+1) + "]"
+			if len(note) > 0 && note[0] != "" && !strings.HasPrefix(note[0], "=>") && !strings.HasPrefix(note[0], "* ") && !strings.HasPrefix(note[0], "```") {
+				note[0] = marker + " " + strings.TrimLeft(note[0], " ")
+			} else {
+				note = append([]string{marker}, note...)
+			}
+			lines = append(lines, note...)
+		}
+	}
+	for _, key := range gw.warnings {
+		wn := gw.warned[key]
+		more := ""
+		if wn.count > 1 {
+			more = fmt.Sprintf(" (%d more)", wn.count-1)
+		}
+		fmt.Fprintf(os.Stderr, "warning: %s line %d: %s%s\n", doc.path, wn.line, wn.msg, more)
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// gemtextWarning is a kind of information lost in conversion, w/ where it's lost first and how often
+type gemtextWarning struct {
+	msg         string
+	line, count int
+}
+
+// gemtextWriter lays out a goldmark AST as gemtext lines
+type gemtextWriter struct {
+	source []byte
+	// footnote definitions by index, footnotes referenced so far in order, laid out, and their numbers
+	// by index
+	footnotes map[int]*east.Footnote
+	notes     [][]string
+	noteNums  map[int]int
+	// link lines of the block being converted, to go after it
+	links []string
+	// warnings by kind, and kinds in order of first occurrence
+	warned   map[string]*gemtextWarning
+	warnings []string
+}
+
+// warn records that n loses information in conversion; 1 warning per kind is shown, at its 1st
+// occurrence
+func (w *gemtextWriter) warn(n ast.Node, kind, msg string) {
+	if wn, ok := w.warned[kind]; ok {
+		wn.count++
+		return
+	}
+	line := 0
+	for p := n; p != nil; p = p.Parent() {
+		if start, _, ok := (&astJSONConverter{source: w.source}).span(p); ok {
+			line = bytes.Count(w.source[:start], []byte("\n")) + 1
+			break
+		}
+	}
+	w.warned[kind] = &gemtextWarning{msg: msg, line: line, count: 1}
+	w.warnings = append(w.warnings, kind)
+}
+
+// blocks converts the children of parent, w/ blank lines between them
+func (w *gemtextWriter) blocks(parent ast.Node) []string {
+	var lines []string
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		b := w.block(c)
+		if b == nil {
+			continue
+		}
+		if lines != nil {
+			lines = append(lines, "")
+		}
+		lines = append(lines, b...)
+	}
+	return lines
+}
+
+// block converts n followed by the link lines of links within it
+func (w *gemtextWriter) block(n ast.Node) []string {
+	outer := w.links
+	w.links = nil
+	lines := w.blockLines(n)
+	if len(w.links) > 0 {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, w.links...)
+	}
+	w.links = outer
+	return lines
+}
+
+func (w *gemtextWriter) blockLines(n ast.Node) []string {
+	switch n := n.(type) {
+	case *ast.Heading:
+		level := n.Level
+		if level > 3 {
+			w.warn(n, "heading", "headings below level 3 capped at level 3")
+			level = 3
+		}
+		return []string{strings.Repeat("#", level) + " " + strings.ReplaceAll(w.inlines(n), "\n", " ")}
+	case *ast.Paragraph, *ast.TextBlock:
+		// a paragraph of just a link, e.g. a standalone image, is its link line
+		if c := n.FirstChild(); c != nil && c.NextSibling() == nil {
+			switch c.(type) {
+			case *ast.Link, *ast.Image, *ast.AutoLink, *wikiLink:
+				if label := w.inline(c); len(w.links) == 0 {
+					return splitGemtextText(label)
+				}
+				return nil
+			}
+		}
+		return splitGemtextText(w.inlines(n))
+	case *ast.ThematicBreak:
+		return []string{"---"}
+	case *ast.CodeBlock:
+		return w.preformatted("", w.code(n))
+	case *ast.FencedCodeBlock:
+		var alt string
+		if n.Info != nil {
+			fi := parseFenceInfo(string(n.Info.Segment.Value(w.source)))
+			alt = strings.TrimSpace(fi.language + " " + fi.title)
+		}
+		return w.preformatted(alt, w.code(n))
+	case *mathBlock:
+		w.warn(n, "math", "math left as TeX source")
+		return w.preformatted("math", strings.Split(strings.TrimRight(mathText(n, w.source), "\n"), "\n"))
+	case *ast.Blockquote:
+		return quoteGemtext(w.blocks(n))
+	case *ast.List:
+		return w.list(n)
+	case *east.DefinitionList:
+		var lines []string
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			if _, ok := c.(*east.DefinitionTerm); ok {
+				lines = append(lines, splitGemtextText(w.inlines(c))...)
+				continue
+			}
+			lines = append(lines, "* "+strings.Join(w.itemText(c), " "))
+		}
+		return lines
+	case *east.Table:
+		var rows [][]string
+		for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+			var cells []string
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, strings.ReplaceAll(w.inlines(cell), "\n", " "))
+			}
+			rows = append(rows, cells)
+		}
+		return w.table(rows, n.Alignments, true)
+	case *csvTable:
+		lines := w.table(n.rows, nil, n.header)
+		if n.omitted > 0 {
+			more := fmt.Sprintf("... %d more rows", n.omitted)
+			if n.omitted == 1 {
+				more = "... 1 more row"
+			}
+			lines = append(lines[:len(lines)-1], more, "```")
+		}
+		return lines
+	case *containerBlock:
+		title := n.title
+		if title == "" {
+			title = strings.ToUpper(n.name[:1]) + n.name[1:]
+		}
+		return quoteGemtext(append([]string{title, ""}, w.blocks(n)...))
+	case *figureBlock:
+		// the image link line, captioned
+		w.inlines(n)
+		lines := w.links
+		w.links = nil
+		if len(n.caption) > 0 {
+			lines = append(lines, splitGemtextText(string(n.caption))...)
+		}
+		return lines
+	case *ast.HTMLBlock:
+		w.warn(n, "html", "raw html dropped")
+		return nil
+	case *east.FootnoteList:
+		// footnotes go to the end
+		return nil
+	}
+	return w.blocks(n)
+}
+
+// list converts a list to list lines; gemtext lists are flat, so nested lists follow their parent
+// item and ordered ones keep their numbers as text
+func (w *gemtextWriter) list(n *ast.List) []string {
+	num := n.Start
+	var lines []string
+	for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+		marker := "* "
+		if n.IsOrdered() {
+			marker = "* " + strconv.Itoa(num) + ". "
+			num++
+		}
+		lines = append(lines, marker+strings.Join(w.itemText(item), " "))
+		for c := item.FirstChild(); c != nil; c = c.NextSibling() {
+			switch c.(type) {
+			case *ast.Paragraph, *ast.TextBlock:
+			case *ast.List:
+				w.warn(c, "list", "nested lists flattened")
+				lines = append(lines, w.block(c)...)
+			default:
+				lines = append(lines, "")
+				lines = append(lines, w.block(c)...)
+			}
+		}
+	}
+	return lines
+}
+
+// itemText returns the text of the paragraphs of a list item or definition
+func (w *gemtextWriter) itemText(item ast.Node) []string {
+	var texts []string
+	for c := item.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c.(type) {
+		case *ast.Paragraph, *ast.TextBlock:
+			texts = append(texts, strings.ReplaceAll(w.inlines(c), "\n", " "))
+		}
+	}
+	return texts
+}
+
+// table lays out a table as text in a preformatted block, as gemtext has no tables
+func (w *gemtextWriter) table(rows [][]string, aligns []east.Alignment, header bool) []string {
+	plain := func(s string) string { return s }
+	// clients don't wrap preformatted text, so there's no width to fit in
+	return w.preformatted("table", boxTable(rows, aligns, header, 1<<16, textBox, plain, plain))
+}
+
+// preformatted returns a preformatted block of lines w/ given alt text
+func (w *gemtextWriter) preformatted(alt string, lines []string) []string {
+	return append(append([]string{"```" + alt}, lines...), "```")
+}
+
+// code returns the lines of a code block
+func (w *gemtextWriter) code(n ast.Node) []string {
+	var b strings.Builder
+	for i := 0; i < n.Lines().Len(); i++ {
+		seg := n.Lines().At(i)
+		b.Write(seg.Value(w.source))
+	}
+	return strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+}
+
+// inlines returns the text of the inline children of parent; soft line breaks become spaces as
+// clients wrap lines, and hard ones newlines
+func (w *gemtextWriter) inlines(parent ast.Node) string {
+	var b strings.Builder
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		b.WriteString(w.inline(c))
+	}
+	return b.String()
+}
+
+func (w *gemtextWriter) inline(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Text:
+		s := leafText(n, w.source)
+		if n.HardLineBreak() {
+			s += "\n"
+		} else if n.SoftLineBreak() {
+			s += " "
+		}
+		return s
+	case *ast.String:
+		return leafText(n, w.source)
+	case *ast.CodeSpan:
+		return "`" + plainText(n, w.source) + "`"
+	case *mathInline:
+		w.warn(n, "math", "math left as TeX source")
+		delim := "$"
+		if n.display {
+			delim = "$$"
+		}
+		return delim + mathText(n, w.source) + delim
+	case *ast.Emphasis:
+		w.warn(n, "emphasis", "emphasis dropped, gemtext has no inline styling")
+		return w.inlines(n)
+	case *east.Strikethrough:
+		w.warn(n, "strikethrough", "strikethrough dropped, gemtext has no inline styling")
+		return w.inlines(n)
+	case *inlineTag:
+		w.warn(n, n.syntax.tag, "<"+n.syntax.tag+"> dropped, gemtext has no inline styling")
+		return w.inlines(n)
+	case *ast.Link:
+		return w.link(n, string(n.Destination), w.inlines(n))
+	case *ast.AutoLink:
+		url := string(n.URL(w.source))
+		if n.AutoLinkType == ast.AutoLinkEmail && !strings.HasPrefix(strings.ToLower(url), "mailto:") {
+			url = "mailto:" + url
+		}
+		return w.link(n, url, string(n.Label(w.source)))
+	case *wikiLink:
+		return w.link(n, n.href, w.inlines(n))
+	case *ast.Image:
+		alt := plainText(n, w.source)
+		w.link(n, string(n.Destination), alt)
+		return alt
+	case *east.TaskCheckBox:
+		if n.IsChecked {
+			return "[x] "
+		}
+		return "[ ] "
+	case *east.FootnoteLink:
+		fn, ok := w.footnotes[n.Index]
+		if !ok {
+			return ""
+		}
+		num, ok := w.noteNums[n.Index]
+		if !ok {
+			// link lines of footnotes stay w/ the footnotes
+			links := w.links
+			w.links = nil
+			w.notes = append(w.notes, nil)
+			num = len(w.notes)
+			w.noteNums[n.Index] = num
+			note := w.blocks(fn)
+			if len(w.links) > 0 {
+				note = append(append(note, ""), w.links...)
+			}
+			w.notes[num-1] = note
+			w.links = links
+		}
+		return "[" + strconv.Itoa(num) + "]"
+	case *ast.RawHTML:
+		w.warn(n, "html", "raw html dropped")
+		return ""
+	case *east.FootnoteBacklink, *imageAttrs:
+		return ""
+	}
+	return w.inlines(n)
+}
+
+// link returns the label of a link, adding its link line to go after the block
+func (w *gemtextWriter) link(n ast.Node, dest, label string) string {
+	if dest == "" {
+		return label
+	}
+	if strings.HasPrefix(dest, "#") {
+		w.warn(n, "fragment", "links within the doc dropped, gemtext can't link to headings")
+		return label
+	}
+	line := "=> " + dest
+	if label = strings.TrimSpace(strings.ReplaceAll(label, "\n", " ")); label != "" && label != dest {
+		line += " " + label
+	}
+	for _, l := range w.links {
+		if l == line {
+			return label
+		}
+	}
+	w.links = append(w.links, line)
+	return label
+}
+
+// splitGemtextText splits text at hard line breaks into text lines, escaping lines which gemtext
+// would take as other line types
+func splitGemtextText(s string) []string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		for _, p := range []string{"=>", "```", "#", "* ", ">"} {
+			if strings.HasPrefix(l, p) {
+				// lines of other types start w/ their prefix, so a leading space keeps them text
+				lines[i] = " " + l
+				break
+			}
+		}
+	}
+	return lines
+}
+
+// quoteGemtext turns the text lines among lines into quote lines; link and list lines as well as
+// preformatted blocks have no quoted form, so they stay as is
+func quoteGemtext(lines []string) []string {
+	out := make([]string, len(lines))
+	pre := false
+	for i, l := range lines {
+		out[i] = l
+		switch {
+		case strings.HasPrefix(l, "```"):
+			pre = !pre
+		case pre, strings.HasPrefix(l, "=>"), strings.HasPrefix(l, "* "):
+		case l == "", strings.HasPrefix(l, ">"):
+			out[i] = ">" + l
+		default:
+			out[i] = "> " + strings.TrimLeft(l, " ")
+		}
+	}
+	return out
+}
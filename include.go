@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// include directives; each takes a path plus an optional heading level shift e.g.
+//
+//	<!-- include: ../shared/escalation.md shift=1 -->
+//	{{< include ../shared/escalation.md shift=1 >}}
+var (
+	includeCommentRe   = regexp.MustCompile(`^<!--\s*include:\s*(.+?)\s*-->$`)
+	includeShortcodeRe = regexp.MustCompile(`^\{\{<\s*include\s+(.+?)\s*>\}\}$`)
+)
+
+// sourceEdit replaces source[start:stop] w/ text
+type sourceEdit struct {
+	start, stop int
+	text        []byte
+}
+
+// applyEdits applies non-overlapping edits to src
+func applyEdits(src []byte, edits []sourceEdit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var out bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		out.Write(src[last:e.start])
+		out.Write(e.text)
+		last = e.stop
+	}
+	out.Write(src[last:])
+	return out.Bytes()
+}
+
+// editedOffset maps an offset into the result of applying edits back to the edited source; offsets
+// within replacement text map to the start of what got replaced, or to its end for ends of ranges
+func editedOffset(edits []sourceEdit, offset int, end bool) int {
+	delta := 0
+	for _, e := range edits {
+		if at := e.start + delta; offset < at || offset == at && end {
+			break
+		} else if offset < at+len(e.text) || offset == at+len(e.text) && !end {
+			if end {
+				return e.stop
+			}
+			return e.start
+		}
+		delta += len(e.text) - (e.stop - e.start)
+	}
+	return offset - delta
+}
+
+// expandIncludes replaces include directives in src, which is read from path ("-" for stdin), w/
+// content of the included files. Included paths resolve relative to the including file. The edits
+// made are returned as well, for mapping offsets back to src.
+func expandIncludes(md goldmark.Markdown, path string, src []byte) ([]byte, []sourceEdit, error) {
+	dir := inputDir(path)
+	if path != "" && path != "-" {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+	edits, err := includeEdits(md, dir, src, []string{path})
+	if err != nil {
+		return nil, nil, err
+	}
+	return applyEdits(src, edits), edits, nil
+}
+
+// stack holds absolute paths of the files being expanded, for cycle detection
+func expandIncludesIn(md goldmark.Markdown, dir string, src []byte, stack []string) ([]byte, error) {
+	edits, err := includeEdits(md, dir, src, stack)
+	if err != nil {
+		return nil, err
+	}
+	return applyEdits(src, edits), nil
+}
+
+func includeEdits(md goldmark.Markdown, dir string, src []byte, stack []string) ([]sourceEdit, error) {
+	doc := md.Parser().Parse(text.NewReader(src))
+	var edits []sourceEdit
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || n.Type() != ast.TypeBlock {
+			return ast.WalkContinue, nil
+		}
+		var re *regexp.Regexp
+		switch n.Kind() {
+		case ast.KindHTMLBlock:
+			re = includeCommentRe
+		case ast.KindParagraph, ast.KindTextBlock:
+			re = includeShortcodeRe
+		default:
+			return ast.WalkContinue, nil
+		}
+		start, stop, ok := blockRange(n, src)
+		if !ok {
+			return ast.WalkSkipChildren, nil
+		}
+		m := re.FindSubmatch(bytes.TrimSpace(src[start:stop]))
+		if m == nil {
+			return ast.WalkSkipChildren, nil
+		}
+		args, attrs := parseDirectiveArgs(string(m[1]))
+		if len(args) != 1 {
+			return ast.WalkStop, fmt.Errorf("include directive %q: expect exactly 1 path", m[0])
+		}
+		shift := 0
+		if s, ok := attrs["shift"]; ok {
+			var err error
+			if shift, err = strconv.Atoi(s); err != nil {
+				return ast.WalkStop, fmt.Errorf("include directive %q: invalid shift: %w", m[0], err)
+			}
+		}
+		included, err := includeFile(md, filepath.Join(dir, args[0]), shift, stack)
+		if err != nil {
+			return ast.WalkStop, err
+		}
+		edits = append(edits, sourceEdit{start: start, stop: stop, text: indentIncluded(src, start, included)})
+		return ast.WalkSkipChildren, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+func includeFile(md goldmark.Markdown, path string, shift int, stack []string) ([]byte, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving included file %s: %w", path, err)
+	}
+	for _, p := range stack {
+		if p == abs {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), abs)
+		}
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading included file %s: %w", path, err)
+	}
+	// front matter of included files is of no use to the including doc
+	_, src = splitFrontMatter(src)
+	src, err = expandIncludesIn(md, filepath.Dir(path), src, append(stack, abs))
+	if err != nil {
+		return nil, err
+	}
+	// snippets referenced by the included file resolve relative to it as well
+	if src, err = expandSnippetsIn(md, filepath.Dir(path), src); err != nil {
+		return nil, err
+	}
+	if shift != 0 {
+		src = shiftHeadings(md, src, shift)
+	}
+	return bytes.TrimRight(src, "\n"), nil
+}
+
+// blockRange returns the source range covered by the lines of a leaf block, w/o the final newline
+func blockRange(n ast.Node, src []byte) (start, stop int, ok bool) {
+	lines := n.Lines()
+	if lines.Len() == 0 {
+		return 0, 0, false
+	}
+	start, stop = lines.At(0).Start, lines.At(lines.Len()-1).Stop
+	if html, isHTML := n.(*ast.HTMLBlock); isHTML && html.HasClosure() {
+		stop = html.ClosureLine.Stop
+	}
+	for stop > start && (src[stop-1] == '\n' || src[stop-1] == '\r') {
+		stop--
+	}
+	return start, stop, true
+}
+
+// indentIncluded prefixes continuation lines of included content so that it stays inside the
+// blockquotes or list items the directive sits in
+func indentIncluded(src []byte, at int, included []byte) []byte {
+	lineStart := bytes.LastIndexByte(src[:at], '\n') + 1
+	prefix := []byte(strings.Map(func(r rune) rune {
+		if r == '>' || r == '\t' {
+			return r
+		}
+		return ' '
+	}, string(src[lineStart:at])))
+	if len(prefix) == 0 {
+		return included
+	}
+	lines := bytes.Split(included, []byte("\n"))
+	for i := 1; i < len(lines); i++ {
+		if len(lines[i]) == 0 {
+			lines[i] = bytes.TrimRight(prefix, " \t")
+		} else {
+			lines[i] = append(append([]byte(nil), prefix...), lines[i]...)
+		}
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// shiftHeadings moves every heading in src by shift levels, keeping levels within 1 to 6; setext
+// headings are rewritten as ATX ones
+func shiftHeadings(md goldmark.Markdown, src []byte, shift int) []byte {
+	doc := md.Parser().Parse(text.NewReader(src))
+	var edits []sourceEdit
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		level := min(6, max(1, h.Level+shift))
+		marker := strings.Repeat("#", level)
+		lines := h.Lines()
+		if lines.Len() == 0 {
+			// empty headings have no content to locate them by; leave them as they are
+			return ast.WalkSkipChildren, nil
+		}
+		start, stop := lines.At(0).Start, lines.At(lines.Len()-1).Stop
+		// an ATX marker directly precedes the content, give or take some spaces
+		i := start
+		for i > 0 && (src[i-1] == ' ' || src[i-1] == '\t') {
+			i--
+		}
+		j := i
+		for j > 0 && src[j-1] == '#' {
+			j--
+		}
+		if j < i {
+			edits = append(edits, sourceEdit{start: j, stop: i, text: []byte(marker)})
+			return ast.WalkSkipChildren, nil
+		}
+		// setext: fold content into 1 line and drop the underline
+		var content []string
+		for k := 0; k < lines.Len(); k++ {
+			seg := lines.At(k)
+			content = append(content, strings.TrimSpace(string(seg.Value(src))))
+		}
+		end := stop
+		if nl := bytes.IndexByte(src[stop:], '\n'); nl >= 0 {
+			end = stop + nl + 1
+			if ul := bytes.IndexByte(src[end:], '\n'); ul >= 0 {
+				end += ul
+			} else {
+				end = len(src)
+			}
+		}
+		edits = append(edits, sourceEdit{start: start, stop: end, text: []byte(marker + " " + strings.Join(content, " "))})
+		return ast.WalkSkipChildren, nil
+	})
+	return applyEdits(src, edits)
+}
+
+// parseDirectiveArgs splits s at whitespace into positional args and key=value attributes; values
+// may be double quoted to contain spaces
+func parseDirectiveArgs(s string) (args []string, attrs map[string]string) {
+	attrs = map[string]string{}
+	for _, field := range splitQuoted(s) {
+		if k, v, ok := strings.Cut(field, "="); ok && k != "" && !strings.HasPrefix(field, `"`) {
+			attrs[k] = unquote(v)
+		} else {
+			args = append(args, unquote(field))
+		}
+	}
+	return args, attrs
+}
+
+// splitQuoted splits s at whitespace outside of double quotes
+func splitQuoted(s string) []string {
+	var fields []string
+	var cur strings.Builder
+	quoted, inField := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && quoted && i+1 < len(s):
+			cur.WriteByte(c)
+			cur.WriteByte(s[i+1])
+			i++
+		case c == '"':
+			quoted = !quoted
+			cur.WriteByte(c)
+			inField = true
+		case (c == ' ' || c == '\t') && !quoted:
+			if inField {
+				fields = append(fields, cur.String())
+				cur.Reset()
+				inField = false
+			}
+		default:
+			cur.WriteByte(c)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+		return s[1 : len(s)-1]
+	}
+	return s
+}
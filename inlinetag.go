@@ -0,0 +1,98 @@
+package main
+
+import (
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	kindMark = ast.NewNodeKind("Mark")
+	kindSub  = ast.NewNodeKind("Sub")
+	kindSup  = ast.NewNodeKind("Sup")
+	kindKbd  = ast.NewNodeKind("Kbd")
+)
+
+// inline syntax producing html elements which the GitHub CSS styles but Markdown has no syntax for
+var (
+	markSyntax = &inlineTagSyntax{delim: "==", tag: "mark", kind: kindMark}
+	subSyntax  = &inlineTagSyntax{delim: "~", tag: "sub", kind: kindSub}
+	supSyntax  = &inlineTagSyntax{delim: "^", tag: "sup", kind: kindSup}
+	kbdSyntax  = &inlineTagSyntax{delim: "++", tag: "kbd", kind: kindKbd}
+)
+
+// inlineTag is an inline element like `==highlight==` which renders as a plain html element
+type inlineTag struct {
+	ast.BaseInline
+	syntax *inlineTagSyntax
+}
+
+func (n *inlineTag) Kind() ast.NodeKind {
+	return n.syntax.kind
+}
+
+func (n *inlineTag) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// inlineTagSyntax is the goldmark extension, inline parser and delimiter processor of an inlineTag
+// delimited by runs of exactly delim on both sides
+type inlineTagSyntax struct {
+	delim string
+	tag   string
+	kind  ast.NodeKind
+}
+
+func (s *inlineTagSyntax) Extend(m goldmark.Markdown) {
+	// ahead of strikethrough so that `~sub~` is not taken for `~~strikethrough~~`
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(s, 400)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(s, 500)))
+}
+
+func (s *inlineTagSyntax) Trigger() []byte {
+	return []byte{s.delim[0]}
+}
+
+func (s *inlineTagSyntax) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	before := block.PrecendingCharacter()
+	line, segment := block.PeekLine()
+	node := parser.ScanDelimiter(line, before, len(s.delim), s)
+	// longer runs belong to other syntax, e.g. `~~` to strikethrough
+	if node == nil || node.OriginalLength != len(s.delim) || before == rune(s.delim[0]) {
+		return nil
+	}
+	node.Segment = segment.WithStop(segment.Start + node.OriginalLength)
+	block.Advance(node.OriginalLength)
+	pc.PushDelimiter(node)
+	return node
+}
+
+func (s *inlineTagSyntax) CloseBlock(parent ast.Node, pc parser.Context) {}
+
+func (s *inlineTagSyntax) IsDelimiter(b byte) bool {
+	return b == s.delim[0]
+}
+
+func (s *inlineTagSyntax) CanOpenCloser(opener, closer *parser.Delimiter) bool {
+	return opener.Processor == closer.Processor
+}
+
+func (s *inlineTagSyntax) OnMatch(consumes int) ast.Node {
+	return &inlineTag{syntax: s}
+}
+
+func (s *inlineTagSyntax) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(s.kind, s.render)
+}
+
+func (s *inlineTagSyntax) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		_, _ = w.WriteString("<" + s.tag + ">")
+	} else {
+		_, _ = w.WriteString("</" + s.tag + ">")
+	}
+	return ast.WalkContinue, nil
+}
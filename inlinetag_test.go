@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestInlineTags(t *testing.T) {
+	tests := []struct {
+		name, md, want string
+	}{
+		{name: "mark", md: "a ==b c== d", want: "<p>a <mark>b c</mark> d</p>"},
+		{name: "sub", md: "H~2~O", want: "<p>H<sub>2</sub>O</p>"},
+		{name: "sup", md: "x^2^", want: "<p>x<sup>2</sup></p>"},
+		{name: "kbd", md: "++Ctrl++ + ++C++", want: "<p><kbd>Ctrl</kbd> + <kbd>C</kbd></p>"},
+		{name: "strikethrough next to sub", md: "~~a~~ H~2~O", want: "<p><del>a</del> H<sub>2</sub>O</p>"},
+		{name: "nested", md: "==a **b** x^2^==", want: "<p><mark>a <strong>b</strong> x<sup>2</sup></mark></p>"},
+		{name: "unclosed", md: "a ==b and x^2", want: "<p>a ==b and x^2</p>"},
+		{name: "longer runs", md: "a === b +++ c", want: "<p>a === b +++ c</p>"},
+		{name: "code span", md: "`==a==`", want: "<p><code>==a==</code></p>"},
+	}
+	md, err := markdownFor(markdownOptions{profile: "gfm-doc", ext: "+mark,+sub,+sup,+kbd"}, nil, &config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := md.Convert([]byte(tt.md), &out); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(out.String()); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
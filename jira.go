@@ -0,0 +1,367 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// jiraEscaper escapes the characters Jira wiki markup takes as markup in text
+var jiraEscaper = strings.NewReplacer(`\`, `\\`, "{", `\{`, "}", `\}`, "[", `\[`, "]", `\]`, "|", `\|`, "!", `\!`,
+	"*", `\*`, "_", `\_`, "+", `\+`, "^", `\^`, "~", `\~`)
+
+// jiraLineStartRe matches the starts of lines Jira takes as block markup, e.g. headings, lists or
+// rules
+var jiraLineStartRe = regexp.MustCompile(`(?m)^(h[1-6]\. |bq\. |[#-]+ |----)`)
+
+// background colors of panels of alert containers
+var jiraPanelColors = map[string]string{
+	"note":      "#deebff",
+	"tip":       "#e3fcef",
+	"important": "#eae6ff",
+	"warning":   "#fffae6",
+	"caution":   "#ffebe6",
+}
+
+// renderJira converts the doc to Jira wiki markup, e.g. for ticket descriptions and comments. Local
+// images refer to attachments of the issue by file name.
+func renderJira(w io.Writer, md goldmark.Markdown, doc *document) error {
+	root := md.Parser().Parse(text.NewReader(doc.source))
+	jw := &jiraWriter{source: doc.source, footnotes: map[int]*east.Footnote{}, noteNums: map[int]int{}}
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if fn, ok := n.(*east.Footnote); ok && entering {
+			jw.footnotes[fn.Index] = fn
+		}
+		return ast.WalkContinue, nil
+	})
+	blocks := []string{jw.blocks(root, "")}
+	if len(jw.notes) > 0 {
+		blocks = append(blocks, "----")
+		for i, note := range jw.notes {
+			blocks = append(blocks, "{anchor:fn-"+strconv.Itoa(i+1)+"}^"+strconv.Itoa(i+1)+"^ "+note)
+		}
+	}
+	_, err := io.WriteString(w, strings.Join(blocks, "\n\n")+"\n")
+	return err
+}
+
+// jiraWriter converts a goldmark AST to Jira wiki markup
+type jiraWriter struct {
+	source []byte
+	// footnote definitions by index, footnotes referenced so far in order, converted, and their
+	// numbers by index
+	footnotes map[int]*east.Footnote
+	notes     []string
+	noteNums  map[int]int
+}
+
+// blocks converts the children of parent, w/ blank lines between them; bullets are the list markers
+// of the lists parent is in, e.g. `*#` in a numbered list in a bulleted one
+func (w *jiraWriter) blocks(parent ast.Node, bullets string) string {
+	var parts []string
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		if b := w.block(c, bullets); b != "" {
+			parts = append(parts, b)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func (w *jiraWriter) block(n ast.Node, bullets string) string {
+	switch n := n.(type) {
+	case *ast.Heading:
+		s := "h" + strconv.Itoa(n.Level) + ". "
+		// headings are anchored by their IDs, for links within the doc
+		if id, ok := n.AttributeString("id"); ok {
+			s += "{anchor:" + string(id.([]byte)) + "}"
+		}
+		return s + strings.ReplaceAll(w.inlines(n), "\n", " ")
+	case *ast.Paragraph, *ast.TextBlock:
+		return w.inlines(n)
+	case *ast.ThematicBreak:
+		return "----"
+	case *ast.CodeBlock:
+		return w.code(n, fenceInfo{})
+	case *ast.FencedCodeBlock:
+		var fi fenceInfo
+		if n.Info != nil {
+			fi = parseFenceInfo(string(n.Info.Segment.Value(w.source)))
+		}
+		return w.code(n, fi)
+	case *mathBlock:
+		return w.code(n, fenceInfo{title: "Math"})
+	case *ast.Blockquote:
+		if alert, marker := blockquoteAlert(n, w.source); alert != "" {
+			var parts []string
+			// the 1st paragraph w/o the marker line
+			if p := n.FirstChild(); p.ChildCount() > marker {
+				var b strings.Builder
+				for c, i := p.FirstChild(), 0; c != nil; c, i = c.NextSibling(), i+1 {
+					if i >= marker {
+						b.WriteString(w.inline(c))
+					}
+				}
+				parts = append(parts, jiraLineStartRe.ReplaceAllString(b.String(), `\$1`))
+			}
+			for c := n.FirstChild().NextSibling(); c != nil; c = c.NextSibling() {
+				if b := w.block(c, ""); b != "" {
+					parts = append(parts, b)
+				}
+			}
+			return jiraPanel(strings.ToUpper(alert[:1])+alert[1:], alert, strings.Join(parts, "\n\n"))
+		}
+		return "{quote}\n" + w.blocks(n, "") + "\n{quote}"
+	case *ast.List:
+		return w.list(n, bullets)
+	case *east.DefinitionList:
+		var parts []string
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			if _, ok := c.(*east.DefinitionTerm); ok {
+				parts = append(parts, "*"+w.inlines(c)+"*")
+				continue
+			}
+			parts = append(parts, "bq. "+strings.ReplaceAll(w.blocks(c, ""), "\n\n", "\n"))
+		}
+		return strings.Join(parts, "\n")
+	case *east.Table:
+		var lines []string
+		for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+			sep := "|"
+			if _, ok := row.(*east.TableHeader); ok {
+				sep = "||"
+			}
+			var cells []string
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				// cells can't be empty
+				cells = append(cells, jiraCell(w.inlines(cell)))
+			}
+			lines = append(lines, sep+strings.Join(cells, sep)+sep)
+		}
+		return strings.Join(lines, "\n")
+	case *csvTable:
+		var lines []string
+		for r, row := range n.rows {
+			sep := "|"
+			if r == 0 && n.header {
+				sep = "||"
+			}
+			var cells []string
+			for _, cell := range row {
+				cells = append(cells, jiraCell(jiraEscaper.Replace(cell)))
+			}
+			lines = append(lines, sep+strings.Join(cells, sep)+sep)
+		}
+		if n.omitted == 1 {
+			lines = append(lines, "|_1 more row_|")
+		} else if n.omitted > 0 {
+			lines = append(lines, fmt.Sprintf("|_%d more rows_|", n.omitted))
+		}
+		return strings.Join(lines, "\n")
+	case *containerBlock:
+		title := n.title
+		if title == "" {
+			title = strings.ToUpper(n.name[:1]) + n.name[1:]
+		}
+		return jiraPanel(title, alertContainers[n.name], w.blocks(n, ""))
+	case *figureBlock:
+		s := w.inlines(n)
+		if len(n.caption) > 0 {
+			s += "\n_" + jiraEscaper.Replace(string(n.caption)) + "_"
+		}
+		return s
+	case *ast.HTMLBlock, *east.FootnoteList:
+		// raw html is not shown, as by default in html output; footnotes go to the end
+		return ""
+	}
+	return w.blocks(n, bullets)
+}
+
+// list converts a list; nested lists continue the markers of their parents, as Jira nests lists by
+// their markers rather than indentation
+func (w *jiraWriter) list(n *ast.List, bullets string) string {
+	marker := "*"
+	if n.IsOrdered() {
+		marker = "#"
+	}
+	bullets += marker
+	var lines []string
+	for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+		var texts []string
+		for c := item.FirstChild(); c != nil; c = c.NextSibling() {
+			if l, ok := c.(*ast.List); ok {
+				if len(texts) > 0 {
+					lines = append(lines, bullets+" "+strings.Join(texts, "\n"))
+					texts = nil
+				}
+				lines = append(lines, w.list(l, bullets))
+				continue
+			}
+			// items are single blocks, so their paragraphs are lines of 1
+			texts = append(texts, w.block(c, bullets))
+		}
+		if len(texts) > 0 {
+			lines = append(lines, bullets+" "+strings.Join(texts, "\n"))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// code converts a code block to a code macro, or a noformat one w/o language
+func (w *jiraWriter) code(n ast.Node, fi fenceInfo) string {
+	var b strings.Builder
+	for i := 0; i < n.Lines().Len(); i++ {
+		seg := n.Lines().At(i)
+		b.Write(seg.Value(w.source))
+	}
+	macro, params := "noformat", []string{}
+	if fi.language != "" {
+		macro = "code"
+		params = append(params, fi.language)
+	}
+	if fi.title != "" {
+		params = append(params, "title="+jiraParam(fi.title))
+	}
+	open := "{" + macro
+	if len(params) > 0 {
+		open += ":" + strings.Join(params, "|")
+	}
+	return open + "}\n" + strings.TrimRight(b.String(), "\n") + "\n{" + macro + "}"
+}
+
+// inlines returns the markup of the inline children of parent; text starting lines like block markup
+// gets escaped
+func (w *jiraWriter) inlines(parent ast.Node) string {
+	var b strings.Builder
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		b.WriteString(w.inline(c))
+	}
+	return jiraLineStartRe.ReplaceAllString(b.String(), `\$1`)
+}
+
+func (w *jiraWriter) inline(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Text:
+		s := jiraEscaper.Replace(leafText(n, w.source))
+		if n.HardLineBreak() {
+			s += "\n"
+		} else if n.SoftLineBreak() {
+			// Jira keeps line breaks, so soft ones go
+			s += " "
+		}
+		return s
+	case *ast.String:
+		return jiraEscaper.Replace(leafText(n, w.source))
+	case *ast.CodeSpan:
+		return "{{" + jiraEscaper.Replace(plainText(n, w.source)) + "}}"
+	case *mathInline:
+		return "{{" + jiraEscaper.Replace(mathText(n, w.source)) + "}}"
+	case *ast.Emphasis:
+		if n.Level >= 2 {
+			return "*" + w.inlines(n) + "*"
+		}
+		return "_" + w.inlines(n) + "_"
+	case *east.Strikethrough:
+		return "-" + w.inlines(n) + "-"
+	case *inlineTag:
+		switch n.syntax.tag {
+		case "sub":
+			return "~" + w.inlines(n) + "~"
+		case "sup":
+			return "^" + w.inlines(n) + "^"
+		case "kbd":
+			return "{{" + w.inlines(n) + "}}"
+		case "mark":
+			return "{color:#974f0c}" + w.inlines(n) + "{color}"
+		}
+		return w.inlines(n)
+	case *ast.Link:
+		return w.link(string(n.Destination), w.inlines(n))
+	case *ast.AutoLink:
+		url := string(n.URL(w.source))
+		if n.AutoLinkType == ast.AutoLinkEmail && !strings.HasPrefix(strings.ToLower(url), "mailto:") {
+			url = "mailto:" + url
+		}
+		return w.link(url, jiraEscaper.Replace(string(n.Label(w.source))))
+	case *wikiLink:
+		return w.link(n.href, w.inlines(n))
+	case *ast.Image:
+		dest := string(n.Destination)
+		if !strings.Contains(dest, "://") {
+			// local images are attachments
+			dest = path.Base(dest)
+		}
+		var params []string
+		if alt := plainText(n, w.source); alt != "" {
+			params = append(params, "alt="+jiraParam(alt))
+		}
+		if v, ok := n.AttributeString("width"); ok {
+			params = append(params, "width="+jiraParam(string(v.([]byte))))
+		}
+		if len(params) > 0 {
+			dest += "|" + strings.Join(params, ",")
+		}
+		return "!" + dest + "!"
+	case *east.TaskCheckBox:
+		if n.IsChecked {
+			return "(/) "
+		}
+		return "☐ "
+	case *east.FootnoteLink:
+		fn, ok := w.footnotes[n.Index]
+		if !ok {
+			return ""
+		}
+		num, ok := w.noteNums[n.Index]
+		if !ok {
+			w.notes = append(w.notes, "")
+			num = len(w.notes)
+			w.noteNums[n.Index] = num
+			w.notes[num-1] = w.blocks(fn, "")
+		}
+		return "^[" + strconv.Itoa(num) + "|#fn-" + strconv.Itoa(num) + "]^"
+	case *ast.RawHTML, *east.FootnoteBacklink, *imageAttrs:
+		return ""
+	}
+	return w.inlines(n)
+}
+
+// link returns a link w/ given label; links within the doc point to anchors of headings
+func (w *jiraWriter) link(dest, label string) string {
+	if dest == "" {
+		return label
+	}
+	if label == "" || label == jiraEscaper.Replace(dest) {
+		return "[" + dest + "]"
+	}
+	return "[" + strings.ReplaceAll(label, "\n", " ") + "|" + dest + "]"
+}
+
+// jiraPanel returns a panel macro w/ given title and body, colored per alert type if any
+func jiraPanel(title, alert, body string) string {
+	params := "title=" + jiraParam(title)
+	if color, ok := jiraPanelColors[alert]; ok {
+		params += "|bgColor=" + color
+	}
+	return "{panel:" + params + "}\n" + body + "\n{panel}"
+}
+
+// jiraCell returns the markup of a table cell, which must not be empty nor contain line breaks
+func jiraCell(s string) string {
+	if s = strings.ReplaceAll(s, "\n", " "); s == "" {
+		return " "
+	}
+	return s
+}
+
+// jiraParam returns s as value of a macro parameter, w/o the characters separating parameters
+func jiraParam(s string) string {
+	return strings.NewReplacer("|", " ", "}", ")", "{", "(", "=", " ", ",", " ").Replace(s)
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// LaTeX sectioning commands by heading level
+var latexSections = []string{"section", "subsection", "subsubsection", "paragraph", "subparagraph", "subparagraph"}
+
+// languages of fenced code blocks the listings package knows, by the names docs use
+var latexListingsLanguages = map[string]string{
+	"bash": "bash", "c": "C", "c++": "C++", "cpp": "C++", "csh": "csh", "fortran": "Fortran",
+	"haskell": "Haskell", "html": "HTML", "java": "Java", "latex": "TeX", "lisp": "Lisp", "lua": "Lua",
+	"make": "make", "makefile": "make", "matlab": "Matlab", "pascal": "Pascal", "perl": "Perl", "php": "PHP",
+	"py": "Python", "python": "Python", "r": "R", "ruby": "Ruby", "sh": "sh", "shell": "bash", "sql": "SQL",
+	"tex": "TeX", "xml": "XML",
+}
+
+// image formats pdfLaTeX includes
+var latexImageTypes = map[string]bool{".jpeg": true, ".jpg": true, ".pdf": true, ".png": true}
+
+// latexEscaper escapes LaTeX's special characters in text
+var latexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`, `{`, `\{`, `}`, `\}`, `$`, `\$`, `&`, `\&`, `%`, `\%`, `#`, `\#`, `_`, `\_`,
+	`~`, `\textasciitilde{}`, `^`, `\textasciicircum{}`, `<`, `\textless{}`, `>`, `\textgreater{}`,
+)
+
+// latexURLEscaper escapes URLs for \href and \url, which take most characters as is
+var latexURLEscaper = strings.NewReplacer(`\`, `\\`, `#`, `\#`, `%`, `\%`, `{`, `\{`, `}`, `\}`)
+
+// latexTemplateData is what LaTeX templates render w/; strings other than Body and Meta are escaped
+// for LaTeX already
+type latexTemplateData struct {
+	Title, Author, Date, Abstract string
+	// front matter as is
+	Meta map[string]string
+	// the converted doc
+	Body string
+}
+
+// renderLaTeX renders the doc as a LaTeX document, w/ the built-in template or the one of doc.template
+func renderLaTeX(w io.Writer, md goldmark.Markdown, doc *document) error {
+	root := md.Parser().Parse(text.NewReader(doc.source))
+	tmpl := latexTemplate
+	if doc.template != "" {
+		b, err := os.ReadFile(doc.template)
+		if err != nil {
+			return fmt.Errorf("error reading template: %w", err)
+		}
+		if tmpl, err = template.New(filepath.Base(doc.template)).Option("missingkey=zero").Parse(string(b)); err != nil {
+			return fmt.Errorf("error parsing template %s: %w", doc.template, err)
+		}
+	}
+	lw := &latexWriter{source: doc.source, dir: inputDir(doc.path), footnotes: map[int]*east.Footnote{}, noteNums: map[int]int{}}
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if fn, ok := n.(*east.Footnote); ok && entering {
+			lw.footnotes[fn.Index] = fn
+		}
+		return ast.WalkContinue, nil
+	})
+	// an H1 taken for title makes the title rather than a section
+	if doc.meta["title"] == "" {
+		for c := root.FirstChild(); c != nil; c = c.NextSibling() {
+			if h, ok := c.(*ast.Heading); ok && h.Level == 1 {
+				lw.title = h
+				break
+			}
+		}
+	}
+	lw.blocks(root)
+	return tmpl.Execute(w, latexTemplateData{
+		Title:    latexEscaper.Replace(doc.title(root)),
+		Author:   latexEscaper.Replace(doc.meta["author"]),
+		Date:     latexEscaper.Replace(doc.meta["date"]),
+		Abstract: latexEscaper.Replace(doc.meta["description"]),
+		Meta:     doc.meta,
+		Body:     strings.TrimSpace(lw.out.String()) + "\n",
+	})
+}
+
+// latexWriter converts a goldmark AST to LaTeX
+type latexWriter struct {
+	source []byte
+	// directory local images resolve against
+	dir string
+	out strings.Builder
+	// footnote definitions by index, and the numbers of the ones referenced so far
+	footnotes map[int]*east.Footnote
+	noteNums  map[int]int
+	// nesting level of enumerate environments, which counters are named by
+	enumDepth int
+	// heading the doc title comes from, if any
+	title ast.Node
+}
+
+func (w *latexWriter) blocks(parent ast.Node) {
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		w.block(c)
+	}
+}
+
+func (w *latexWriter) block(n ast.Node) {
+	out := &w.out
+	switch n := n.(type) {
+	case *ast.Heading:
+		if n == w.title {
+			return
+		}
+		out.WriteString(`\` + latexSections[n.Level-1] + "{" + w.inlines(n) + "}")
+		if id, ok := n.AttributeString("id"); ok {
+			out.WriteString(`\label{` + string(id.([]byte)) + "}")
+		}
+		out.WriteString("\n\n")
+	case *ast.Paragraph, *ast.TextBlock:
+		out.WriteString(w.inlines(n) + "\n\n")
+	case *ast.ThematicBreak:
+		out.WriteString("\\begin{center}\\rule{0.5\\linewidth}{0.5pt}\\end{center}\n\n")
+	case *ast.CodeBlock:
+		out.WriteString("\\begin{lstlisting}\n" + w.lines(n) + "\\end{lstlisting}\n\n")
+	case *ast.FencedCodeBlock:
+		var opts []string
+		if n.Info != nil {
+			fi := parseFenceInfo(string(n.Info.Segment.Value(w.source)))
+			if lang, ok := latexListingsLanguages[strings.ToLower(fi.language)]; ok {
+				opts = append(opts, "language="+lang)
+			}
+			if fi.title != "" {
+				opts = append(opts, "title={"+latexEscaper.Replace(fi.title)+"}")
+			}
+			if fi.linenos {
+				opts = append(opts, "numbers=left", "firstnumber="+strconv.Itoa(max(fi.start, 1)))
+			}
+		}
+		out.WriteString("\\begin{lstlisting}")
+		if len(opts) > 0 {
+			out.WriteString("[" + strings.Join(opts, ",") + "]")
+		}
+		out.WriteString("\n" + w.lines(n) + "\\end{lstlisting}\n\n")
+	case *mathBlock:
+		out.WriteString("\\[\n" + mathText(n, w.source) + "\\]\n\n")
+	case *ast.Blockquote:
+		out.WriteString("\\begin{quote}\n")
+		w.blocks(n)
+		out.WriteString("\\end{quote}\n\n")
+	case *ast.List:
+		w.list(n)
+	case *east.DefinitionList:
+		out.WriteString("\\begin{description}\n")
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			if _, ok := c.(*east.DefinitionTerm); ok {
+				// braced so that a `]` of the term cannot end the label early
+				out.WriteString("\\item[{" + w.inlines(c) + "}] ")
+				continue
+			}
+			w.blocks(c)
+		}
+		out.WriteString("\\end{description}\n\n")
+	case *east.Table:
+		w.table(n)
+	case *csvTable:
+		w.csvTable(n)
+	case *containerBlock:
+		title := n.title
+		if title == "" {
+			title = strings.ToUpper(n.name[:1]) + n.name[1:]
+		}
+		out.WriteString("\\begin{quote}\n\\textbf{" + latexEscaper.Replace(title) + "}\n\n")
+		w.blocks(n)
+		out.WriteString("\\end{quote}\n\n")
+	case *figureBlock:
+		out.WriteString("\\begin{figure}[htbp]\n\\centering\n" + w.inlines(n) + "\n")
+		if len(n.caption) > 0 {
+			out.WriteString("\\caption{" + latexEscaper.Replace(string(n.caption)) + "}\n")
+		}
+		out.WriteString("\\end{figure}\n\n")
+	case *ast.HTMLBlock, *east.FootnoteList:
+		// raw html means nothing to LaTeX; footnotes go w/ their references
+	default:
+		w.blocks(n)
+	}
+}
+
+// lines concatenates the raw source lines of given block
+func (w *latexWriter) lines(n ast.Node) string {
+	var b strings.Builder
+	for i := 0; i < n.Lines().Len(); i++ {
+		seg := n.Lines().At(i)
+		b.Write(seg.Value(w.source))
+	}
+	return b.String()
+}
+
+func (w *latexWriter) list(n *ast.List) {
+	out := &w.out
+	env := "itemize"
+	if n.IsOrdered() {
+		env = "enumerate"
+		w.enumDepth++
+		defer func() { w.enumDepth-- }()
+	}
+	out.WriteString("\\begin{" + env + "}\n")
+	if n.IsOrdered() && n.Start > 1 && w.enumDepth <= 4 {
+		counters := []string{"i", "ii", "iii", "iv"}
+		fmt.Fprintf(out, "\\setcounter{enum%s}{%d}\n", counters[w.enumDepth-1], n.Start-1)
+	}
+	for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+		// w/o the braces, items starting w/ `[` would have it taken as their label
+		out.WriteString("\\item{} ")
+		w.blocks(item)
+	}
+	out.WriteString("\\end{" + env + "}\n\n")
+}
+
+// table writes a GFM table as a tabular w/ columns aligned per the table
+func (w *latexWriter) table(n *east.Table) {
+	out := &w.out
+	spec := ""
+	for _, a := range n.Alignments {
+		switch a {
+		case east.AlignCenter:
+			spec += "c"
+		case east.AlignRight:
+			spec += "r"
+		default:
+			spec += "l"
+		}
+	}
+	out.WriteString("\\begin{center}\n\\begin{tabular}{" + spec + "}\n\\toprule\n")
+	for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+		var cells []string
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			cells = append(cells, w.inlines(cell))
+		}
+		out.WriteString(strings.Join(cells, " & ") + " \\\\\n")
+		if _, ok := row.(*east.TableHeader); ok {
+			out.WriteString("\\midrule\n")
+		}
+	}
+	out.WriteString("\\bottomrule\n\\end{tabular}\n\\end{center}\n\n")
+}
+
+// csvTable writes CSV data as a tabular
+func (w *latexWriter) csvTable(n *csvTable) {
+	out := &w.out
+	columns := 0
+	for _, row := range n.rows {
+		columns = max(columns, len(row))
+	}
+	out.WriteString("\\begin{center}\n\\begin{tabular}{" + strings.Repeat("l", max(columns, 1)) + "}\n\\toprule\n")
+	for i, row := range n.rows {
+		cells := make([]string, columns)
+		for j := range cells {
+			if j < len(row) {
+				cells[j] = latexEscaper.Replace(row[j])
+			}
+		}
+		out.WriteString(strings.Join(cells, " & ") + " \\\\\n")
+		if i == 0 && n.header {
+			out.WriteString("\\midrule\n")
+		}
+	}
+	if n.omitted > 0 {
+		more := fmt.Sprintf("%d more rows", n.omitted)
+		if n.omitted == 1 {
+			more = "1 more row"
+		}
+		fmt.Fprintf(out, "\\multicolumn{%d}{l}{\\emph{%s}} \\\\\n", max(columns, 1), more)
+	}
+	out.WriteString("\\bottomrule\n\\end{tabular}\n\\end{center}\n\n")
+}
+
+// inlines returns the LaTeX of the inline children of parent
+func (w *latexWriter) inlines(parent ast.Node) string {
+	var b strings.Builder
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		w.inline(&b, c)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func (w *latexWriter) inline(b *strings.Builder, n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Text:
+		b.WriteString(latexEscaper.Replace(leafText(n, w.source)))
+		if n.HardLineBreak() {
+			b.WriteString("\\\\\n")
+		} else if n.SoftLineBreak() {
+			b.WriteString("\n")
+		}
+	case *ast.String:
+		b.WriteString(latexEscaper.Replace(leafText(n, w.source)))
+	case *ast.CodeSpan:
+		b.WriteString("\\texttt{" + latexEscaper.Replace(plainText(n, w.source)) + "}")
+	case *mathInline:
+		// TeX passes through as is
+		if n.display {
+			b.WriteString("\\[" + mathText(n, w.source) + "\\]")
+		} else {
+			b.WriteString("$" + mathText(n, w.source) + "$")
+		}
+	case *ast.Emphasis:
+		cmd := "emph"
+		if n.Level >= 2 {
+			cmd = "textbf"
+		}
+		b.WriteString("\\" + cmd + "{" + w.inlines(n) + "}")
+	case *east.Strikethrough:
+		b.WriteString("\\sout{" + w.inlines(n) + "}")
+	case *inlineTag:
+		cmd := map[string]string{"mark": "hl", "sub": "textsubscript", "sup": "textsuperscript", "kbd": "texttt"}[n.syntax.tag]
+		b.WriteString("\\" + cmd + "{" + w.inlines(n) + "}")
+	case *ast.Link:
+		b.WriteString(latexLink(string(n.Destination), w.inlines(n)))
+	case *ast.AutoLink:
+		url := string(n.URL(w.source))
+		if n.AutoLinkType == ast.AutoLinkEmail {
+			if !strings.HasPrefix(strings.ToLower(url), "mailto:") {
+				url = "mailto:" + url
+			}
+			b.WriteString(latexLink(url, latexEscaper.Replace(string(n.Label(w.source)))))
+			return
+		}
+		b.WriteString("\\url{" + latexURLEscaper.Replace(url) + "}")
+	case *wikiLink:
+		if n.href == "" {
+			b.WriteString(w.inlines(n))
+			return
+		}
+		b.WriteString(latexLink(n.href, w.inlines(n)))
+	case *ast.Image:
+		w.image(b, n)
+	case *east.TaskCheckBox:
+		if n.IsChecked {
+			b.WriteString("$\\boxtimes$~")
+		} else {
+			b.WriteString("$\\square$~")
+		}
+	case *east.FootnoteLink:
+		fn, ok := w.footnotes[n.Index]
+		if !ok {
+			return
+		}
+		// the text goes w/ the first reference, later ones only repeat its mark; numbers are explicit
+		// so that they match regardless of the class resetting counters
+		if num, ok := w.noteNums[n.Index]; ok {
+			b.WriteString("\\footnotemark[" + strconv.Itoa(num) + "]")
+			return
+		}
+		num := len(w.noteNums) + 1
+		w.noteNums[n.Index] = num
+		var body []string
+		for c := fn.FirstChild(); c != nil; c = c.NextSibling() {
+			body = append(body, w.inlines(c))
+		}
+		b.WriteString("\\footnote[" + strconv.Itoa(num) + "]{" + strings.Join(body, "\n\n") + "}")
+	case *ast.RawHTML, *east.FootnoteBacklink, *imageAttrs:
+	default:
+		b.WriteString(w.inlines(n))
+	}
+}
+
+// latexLink links to dest; `#fragment` links go to labels of headings
+func latexLink(dest, label string) string {
+	if anchor, ok := strings.CutPrefix(dest, "#"); ok {
+		return "\\hyperref[" + anchor + "]{" + label + "}"
+	}
+	return "\\href{" + latexURLEscaper.Replace(dest) + "}{" + label + "}"
+}
+
+// image includes a local image pdfLaTeX can take; others become links to them
+func (w *latexWriter) image(b *strings.Builder, n *ast.Image) {
+	dest := string(n.Destination)
+	path, ok := localPath(dest, w.dir)
+	if !ok || !latexImageTypes[strings.ToLower(filepath.Ext(path))] {
+		alt := w.inlines(n)
+		if alt == "" {
+			alt = latexEscaper.Replace(dest)
+		}
+		b.WriteString(latexLink(dest, alt))
+		return
+	}
+	b.WriteString("\\includegraphics")
+	// a width given by attributes wins over the natural width, at 96 pixels per inch, but still fits
+	// in the line
+	if v, ok := n.AttributeString("width"); ok {
+		if px, err := strconv.Atoi(string(v.([]byte))); err == nil && px > 0 {
+			width := fmt.Sprintf("%.2fin", float64(px)/96)
+			fmt.Fprintf(b, "[width={\\ifdim %s>\\linewidth\\linewidth\\else %s\\fi}]", width, width)
+		}
+	}
+	// relative to the doc, next to which the output is expected to be compiled
+	if rel, err := filepath.Rel(w.dir, path); err == nil && !filepath.IsAbs(dest) {
+		path = rel
+	}
+	// graphicx takes / separated paths on every OS
+	b.WriteString("{" + filepath.ToSlash(path) + "}")
+}
+
+// latexTemplate is the built-in template, a plain article. Images are scaled down to the text width,
+// like Pandoc does.
+var latexTemplate = template.Must(template.New("latex").Parse(`\documentclass[11pt]{article}
+\usepackage[T1]{fontenc}
+\usepackage[utf8]{inputenc}
+\usepackage{lmodern}
+\usepackage{amsmath,amssymb}
+\usepackage{graphicx}
+\usepackage{booktabs}
+\usepackage{listings}
+\usepackage{xcolor}
+\usepackage[normalem]{ulem}
+\usepackage{soul}
+\usepackage[margin=1in]{geometry}
+\usepackage{hyperref}
+\hypersetup{colorlinks=true,linkcolor=blue,urlcolor=blue}
+\lstset{basicstyle=\ttfamily\small,breaklines=true,columns=fullflexible,keepspaces=true,frame=single,rulecolor=\color{lightgray},backgroundcolor=\color{gray!5}}
+\makeatletter
+\def\maxwidth{\ifdim\Gin@nat@width>\linewidth\linewidth\else\Gin@nat@width\fi}
+\makeatother
+\setkeys{Gin}{width=\maxwidth,keepaspectratio}
+\setlength{\parindent}{0pt}
+\setlength{\parskip}{6pt plus 2pt minus 1pt}
+{{- if .Title}}
+\title{ {{- .Title -}} }
+\author{ {{- .Author -}} }
+\date{ {{- .Date -}} }
+{{- end}}
+
+\begin{document}
+{{- if .Title}}
+\maketitle
+{{- end}}
+{{- if .Abstract}}
+\begin{abstract}
+{{.Abstract}}
+\end{abstract}
+{{- end}}
+
+{{.Body}}
+\end{document}
+`))
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLaTeXFootnotes(t *testing.T) {
+	tests := []struct {
+		name, md, want string
+	}{
+		{
+			name: "single reference",
+			md:   "A[^n].\n\n[^n]: Note *x*.\n",
+			want: `A\footnote[1]{Note \emph{x}.}.`,
+		},
+		{
+			name: "repeated reference",
+			md:   "A[^n] b[^m] c[^n].\n\n[^n]: Note.\n[^m]: Other.\n",
+			want: `A\footnote[1]{Note.} b\footnote[2]{Other.} c\footnotemark[1].`,
+		},
+		{
+			name: "numbered by first reference",
+			md:   "A[^m] b[^n] c[^m] d[^n].\n\n[^n]: N.\n[^m]: M.\n",
+			want: `A\footnote[1]{M.} b\footnote[2]{N.} c\footnotemark[1] d\footnotemark[2].`,
+		},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "doc.md")
+			if err := os.WriteFile(path, []byte(tt.md), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			doc, err := loadDocument(path, markdownOptions{profile: "rmd-extended"}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := renderLaTeX(&out, doc.md, doc); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), tt.want+"\n") {
+				t.Errorf("output lacks %s:\n%s", tt.want, out.String())
+			}
+		})
+	}
+}
+
+func TestLaTeXLists(t *testing.T) {
+	tests := []struct {
+		name, md, want string
+	}{
+		{
+			name: "item starting w/ a bracket",
+			md:   "- [bracket] first\n- second\n",
+			want: "\\item{} [bracket] first\n",
+		},
+		{
+			name: "ordered item starting w/ a bracket",
+			md:   "1. [1] one\n",
+			want: "\\item{} [1] one\n",
+		},
+		{
+			name: "task item",
+			md:   "- [x] done\n",
+			want: "\\item{} $\\boxtimes$~done\n",
+		},
+		{
+			name: "definition term w/ a bracket",
+			md:   "a]b\n: def\n",
+			want: "\\item[{a]b}] def\n",
+		},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "doc.md")
+			if err := os.WriteFile(path, []byte(tt.md), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			doc, err := loadDocument(path, markdownOptions{profile: "rmd-extended"}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := renderLaTeX(&out, doc.md, doc); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("output lacks %q:\n%s", tt.want, out.String())
+			}
+		})
+	}
+}
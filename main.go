@@ -4,16 +4,23 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
+	stdhtml "html"
 	"html/template"
 	"io"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/yuin/goldmark"
-	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
 )
 
 // Spec
@@ -23,39 +30,78 @@ import (
 // 4. (preview only) Open OS's web page tool for preview
 // 5. (preview only) Delete the temporary file which contains render output for preview
 func main() {
+	// subcommands go before flag parsing since they come w/ their own flag sets
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		fmtMain(os.Args[2:])
+		return
+	}
 	// By default, read from stdin and output to stdout
-	// TODO support multiple input files
 	inPath := flag.String("i", "-", "Input file path")
 	// In preview mode we open the rendered file w/ OS's default web page viewer tool (usually a web browser)
 	// plus we remove the file containing rendered output upon program exit
 	previewOnly := flag.Bool("preview", false, "Preview only")
 	style := flag.Bool("style", false, "Render markdown to html page w/ CSS style (Github Markdown light)")
+	format := flag.String("format", "html", "Output format: html, "+strings.Join(formatNames(), ", ")+" (term when stdout is a terminal and no -style is given)")
+	profileName := flag.String("profile", "", "Markdown dialect profile: "+strings.Join(profileNames(), ", ")+" (default from front matter or config, else "+defaultProfile+")")
+	extFlag := flag.String("ext", "", "Comma separated extensions to enable (+name) or disable (-name) on top of the profile: "+strings.Join(extensionNames(), ", "))
+	htmlMode := flag.String("html", "", "Raw html handling: omit, unsafe or sanitize (default from config, else omit)")
+	unsafeHTML := flag.Bool("unsafe", false, "Pass raw html through as is, same as -html=unsafe")
+	configPath := flag.String("config", "", "Config file path (default "+configFileName+" found from the input file's directory upwards)")
+	templatePath := flag.String("template", "", "Template file of latex output (default from config, else built-in)")
 
 	flag.Parse()
-	var mdTxtReader io.Reader = os.Stdin
-	if p := *inPath; p != "" && p != "-" {
-		f, err := os.Open(p)
+	// on terminals, show the doc itself rather than its html unless asked otherwise
+	formatSet := false
+	flag.Visit(func(f *flag.Flag) { formatSet = formatSet || f.Name == "format" })
+	if !formatSet && !*style && !*previewOnly && isTerminal(os.Stdout) && !strings.EqualFold(filepath.Ext(*inPath), ".ipynb") {
+		*format = "term"
+	}
+	render, ok := outputFormats[*format]
+	if !ok && *format != "html" {
+		panic(fmt.Errorf("unknown output format %q", *format))
+	}
+	// inputs beyond -i come as arguments, for formats packaging many docs into 1 output
+	inPaths := flag.Args()
+	if *inPath != "-" || len(inPaths) == 0 {
+		inPaths = append([]string{*inPath}, inPaths...)
+	}
+	if len(inPaths) > 1 && render.renderDocs == nil {
+		panic(fmt.Errorf("error rendering %d input files: %s output takes 1 input file", len(inPaths), *format))
+	}
+	if *unsafeHTML {
+		*htmlMode = htmlUnsafe
+	}
+	var docs []*document
+	for _, p := range inPaths {
+		doc, err := loadDocument(p, markdownOptions{profile: *profileName, ext: *extFlag, html: *htmlMode}, *configPath)
 		if err != nil {
-			panic(fmt.Errorf("error opening input file %s: %w", p, err))
+			panic(err)
 		}
-		defer f.Close()
-		mdTxtReader = f
-	}
-	mdTxt, err := io.ReadAll(mdTxtReader)
-	if err != nil {
-		panic(fmt.Errorf("error reading all Markdown content from input: %w", err))
+		if doc.notebook && *format != "html" {
+			panic(fmt.Errorf("error rendering notebook %s: only html output is supported", p))
+		}
+		doc.template = resolveTemplate(*templatePath, doc.cfg, *format)
+		docs = append(docs, doc)
 	}
+	doc := docs[0]
 
-	md := goldmark.New(
-		goldmark.WithExtensions(extension.GFM),
-		goldmark.WithRendererOptions(
-			html.WithHardWraps(),
-		),
-	)
 	// By default output converted data to stdout to stay comptible w/ existing shell tools
 	var sink io.Writer = os.Stdout
 	// path to the temp file which contains markdown render output
 	var tmpOut string
+	// page terminal output if a pager is set
+	if pagerCmd := os.Getenv("PAGER"); *format == "term" && !*previewOnly && pagerCmd != "" && isTerminal(os.Stdout) {
+		p, err := startPager(pagerCmd)
+		if err != nil {
+			panic(err)
+		}
+		sink = p
+		defer func() {
+			if err := p.Close(); err != nil {
+				fmt.Fprintln(os.Stderr, fmt.Errorf("error closing pager: %w", err))
+			}
+		}()
+	}
 	if *previewOnly {
 		tmpDir, err := os.MkdirTemp("", "rmd")
 		if err != nil {
@@ -68,7 +114,11 @@ func main() {
 			}
 		}()
 
-		tmpOut = path.Join(tmpDir, "out.html")
+		ext := ".html"
+		if ok {
+			ext = render.ext
+		}
+		tmpOut = path.Join(tmpDir, "out"+ext)
 		if f, err := os.Create(tmpOut); err != nil {
 			panic(fmt.Errorf("error creating temp directory: %w", err))
 		} else {
@@ -93,6 +143,19 @@ func main() {
 		}()
 	}
 
+	if render.renderDocs != nil {
+		if err := render.renderDocs(sink, docs); err != nil {
+			panic(fmt.Errorf("error rendering Markdown to %s: %w", *format, err))
+		}
+		return
+	}
+	if *format != "html" {
+		if err := render.render(sink, doc.md, doc); err != nil {
+			panic(fmt.Errorf("error rendering Markdown to %s: %w", *format, err))
+		}
+		return
+	}
+
 	// if render w/ styling, then inject styling data to sink as well
 	if *style {
 		// Per https://github.com/sindresorhus/github-markdown-css/tree/main?tab=readme-ov-file#usage
@@ -114,7 +177,7 @@ func main() {
 			// unsafe content reached a CSS or URL context at runtime.
 			CSS template.CSS
 		}{
-			CSS: template.CSS(markDownStyleGithubCSS),
+			CSS: template.CSS(markDownStyleGithubCSS + codeBlockCSS + notebookCSS),
 		}
 		if err := htmlPrefixWithCSS.Execute(sink, data); err != nil {
 			panic(fmt.Errorf("error writing html output prefix data to sink: %w", err))
@@ -135,12 +198,260 @@ func main() {
 		}()
 	}
 
+	if doc.notebook {
+		// html outputs of notebooks are sanitized unless raw html is trusted
+		var policy *htmlPolicy
+		if resolveHTMLMode(*htmlMode, doc.cfg) != htmlUnsafe {
+			policy = newHTMLPolicy(doc.cfg.HTML.Allow, doc.cfg.HTML.Deny)
+		}
+		if err := renderNotebook(sink, doc.md, doc.source, policy); err != nil {
+			panic(fmt.Errorf("error rendering notebook: %w", err))
+		}
+		return
+	}
 	// convert given Markdown text and output
-	if err := md.Convert(mdTxt, sink); err != nil {
+	if err := doc.md.Convert(doc.source, sink); err != nil {
 		panic(fmt.Errorf("error rendering Markdown: %w", err))
 	}
 }
 
+// document is a Markdown doc to be rendered
+type document struct {
+	// path of the input file; "-" for stdin
+	path   string
+	source []byte
+	// the input as read, where its body starts past front matter, and the edits of include and
+	// snippet expansion in order, which turned the body into source
+	input      []byte
+	bodyStart  int
+	expansions [][]sourceEdit
+	// front matter
+	meta map[string]string
+	// config in effect for the doc, goldmark configured per it, and the names of the extensions
+	// enabled, for formats which follow them beyond goldmark's renderer
+	cfg        *config
+	md         goldmark.Markdown
+	extensions []string
+	// whether the doc is a Jupyter notebook rather than Markdown
+	notebook bool
+	// template file overriding the built-in one of the output format, if any
+	template string
+}
+
+// loadDocument reads the doc at path ("-" for stdin) and prepares it for rendering: front matter is
+// split off, goldmark gets configured per opts, front matter and config, and includes and snippets are
+// expanded so that every output format and mode sees the complete doc
+func loadDocument(path string, opts markdownOptions, configPath string) (*document, error) {
+	var r io.Reader = os.Stdin
+	if path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("error opening input file %s: %w", path, err)
+		}
+		defer f.Close()
+		r = f
+	}
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading all Markdown content from input: %w", err)
+	}
+	// notebooks are JSON holding Markdown, code and outputs per cell rather than 1 Markdown doc
+	doc := &document{path: path, input: src, notebook: strings.EqualFold(filepath.Ext(path), ".ipynb")}
+	if !doc.notebook {
+		doc.meta, src = splitFrontMatter(src)
+		doc.bodyStart = len(doc.input) - len(src)
+	}
+	if doc.cfg, err = loadConfig(configPath, inputDir(path)); err != nil {
+		return nil, err
+	}
+	opts.docDir = inputDir(path)
+	if doc.md, err = markdownFor(opts, doc.meta, doc.cfg); err != nil {
+		return nil, err
+	}
+	// resolving cannot fail once markdownFor succeeded
+	doc.extensions, _ = resolveExtensions(resolveProfile(opts.profile, doc.meta, doc.cfg), doc.cfg, opts.ext)
+	if !doc.notebook {
+		var edits []sourceEdit
+		if src, edits, err = expandIncludes(doc.md, path, src); err != nil {
+			return nil, fmt.Errorf("error expanding includes: %w", err)
+		}
+		doc.expansions = append(doc.expansions, edits)
+		if src, edits, err = expandSnippets(doc.md, path, src); err != nil {
+			return nil, fmt.Errorf("error expanding code snippets: %w", err)
+		}
+		doc.expansions = append(doc.expansions, edits)
+	}
+	doc.source = src
+	return doc, nil
+}
+
+// inputOffset maps an offset into source back to the input; content of includes and snippets maps
+// to the directive or code block it replaced, to its end for ends of ranges
+func (d *document) inputOffset(offset int, end bool) int {
+	for i := len(d.expansions) - 1; i >= 0; i-- {
+		offset = editedOffset(d.expansions[i], offset, end)
+	}
+	return d.bodyStart + offset
+}
+
+// title returns the title of the doc per front matter, else its first heading of the top level
+func (d *document) title(root ast.Node) string {
+	if t := d.meta["title"]; t != "" {
+		return t
+	}
+	for c := root.FirstChild(); c != nil; c = c.NextSibling() {
+		if h, ok := c.(*ast.Heading); ok && h.Level == 1 {
+			return plainText(h, d.source)
+		}
+	}
+	return ""
+}
+
+// leafText returns the text a Text or String node stands for, w/ escapes and character references
+// resolved
+func leafText(n ast.Node, source []byte) string {
+	switch n := n.(type) {
+	case *ast.Text:
+		v := n.Segment.Value(source)
+		if !n.IsRaw() {
+			v = util.ResolveEntityNames(util.ResolveNumericReferences(util.UnescapePunctuations(v)))
+		}
+		return string(v)
+	case *ast.String:
+		// code strings hold html e.g. `&ldquo;` of the typographer
+		if n.IsCode() {
+			return stdhtml.UnescapeString(string(n.Value))
+		}
+		return string(n.Value)
+	}
+	return ""
+}
+
+// plainText returns the text content of n w/o any markup; line breaks become spaces
+func plainText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch c := c.(type) {
+		case *ast.Text:
+			b.WriteString(leafText(c, source))
+			if c.SoftLineBreak() || c.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.WriteString(leafText(c, source))
+		case *ast.AutoLink:
+			b.Write(c.Label(source))
+		case *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(b.String())
+}
+
+// outputFormat converts Markdown docs to a format other than (the default) html
+type outputFormat struct {
+	// file extension of converted output, e.g. for preview
+	ext    string
+	render func(w io.Writer, md goldmark.Markdown, doc *document) error
+	// in place of render for formats which package 1 or more docs into 1 output
+	renderDocs func(w io.Writer, docs []*document) error
+}
+
+var outputFormats = map[string]outputFormat{
+	"ast-json":   {ext: ".json", render: renderASTJSON},
+	"slides":     {ext: ".html", render: renderSlides},
+	"epub":       {ext: ".epub", renderDocs: renderEPUB},
+	"docx":       {ext: ".docx", render: renderDOCX},
+	"latex":      {ext: ".tex", render: renderLaTeX},
+	"man":        {ext: ".1", render: renderMan},
+	"term":       {ext: ".txt", render: renderTerm},
+	"text":       {ext: ".txt", render: renderText},
+	"gemtext":    {ext: ".gmi", render: renderGemtext},
+	"slack":      {ext: ".jsonl", render: renderSlack},
+	"confluence": {ext: ".xml", render: renderConfluence},
+	"jira":       {ext: ".txt", render: renderJira},
+}
+
+func formatNames() []string {
+	var names []string
+	for name := range outputFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newMarkdown returns the goldmark converter w/ given extensions enabled; rendering and formatting
+// share it so that both see the same document structure
+func newMarkdown(extNames []string, cfg *config, opts markdownOptions) goldmark.Markdown {
+	var gopts []goldmark.Option
+	for _, name := range extNames {
+		gopts = append(gopts, markdownExtensions[name].options(cfg, opts)...)
+	}
+	return goldmark.New(gopts...)
+}
+
+// markdownOptions are the per run choices, usually from flags, which shape the goldmark converter
+type markdownOptions struct {
+	// profile name
+	profile string
+	// extension adjustments e.g. "+deflist,-strikethrough"
+	ext string
+	// raw html mode
+	html string
+	// directory of the doc being converted
+	docDir string
+}
+
+// markdownFor returns the goldmark converter of a doc w/ given front matter, picking profile,
+// extensions and raw html handling from options, front matter and config
+func markdownFor(opts markdownOptions, meta map[string]string, cfg *config) (goldmark.Markdown, error) {
+	names, err := resolveExtensions(resolveProfile(opts.profile, meta, cfg), cfg, opts.ext)
+	if err != nil {
+		return nil, err
+	}
+	md := newMarkdown(names, cfg, opts)
+	switch mode := resolveHTMLMode(opts.html, cfg); mode {
+	case "", htmlOmit:
+	case htmlUnsafe:
+		md.Renderer().AddOptions(html.WithUnsafe())
+	case htmlSanitize:
+		// sanitizing happens in place of goldmark's raw html rendering; unsafe mode stays off, as it
+		// would let `javascript:` URLs of Markdown links and images through as well
+		md.Renderer().AddOptions(
+			renderer.WithNodeRenderers(util.Prioritized(&sanitizingHTMLRenderer{
+				Config: html.NewConfig(),
+				policy: newHTMLPolicy(cfg.HTML.Allow, cfg.HTML.Deny),
+			}, 100)),
+		)
+		md.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(safeURLTransformer{}, 1000)))
+	default:
+		return nil, fmt.Errorf("unknown html mode %q, expect one of %s, %s, %s", mode, htmlOmit, htmlUnsafe, htmlSanitize)
+	}
+	return md, nil
+}
+
+func profileNames() []string {
+	var names []string
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inputDir returns the directory relative paths in given input file resolve against
+func inputDir(inPath string) string {
+	if inPath == "" || inPath == "-" {
+		return "."
+	}
+	return filepath.Dir(inPath)
+}
+
 // https://github.com/sindresorhus/github-markdown-css/blob/9ab210a7b09f657d0b79321e8135017d9d64236a/github-markdown-light.css
 const markDownStyleGithubCSS = `
 /* light */
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files of testdata/golden w/ the current output")
+
+// TestGolden renders testdata/golden.md to every format and compares w/ testdata/golden/<format><ext>
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		format string
+		// file of zip based formats to compare, as the rest carries e.g. timestamps
+		part string
+		// environment of the run
+		env map[string]string
+	}{
+		{format: "ast-json"},
+		{format: "slides"},
+		{format: "epub", part: "OEBPS/chapter-1.xhtml"},
+		{format: "docx", part: "word/document.xml"},
+		{format: "latex"},
+		{format: "man"},
+		{format: "term", env: map[string]string{"NO_COLOR": "", "COLUMNS": "80"}},
+		{format: "text"},
+		{format: "gemtext"},
+		{format: "slack"},
+		{format: "confluence"},
+		{format: "jira"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			doc, err := loadDocument(filepath.Join("testdata", "golden.md"), markdownOptions{profile: "rmd-extended"}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := outputFormats[tt.format]
+			var out bytes.Buffer
+			if f.renderDocs != nil {
+				err = f.renderDocs(&out, []*document{doc})
+			} else {
+				err = f.render(&out, doc.md, doc)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, path := out.Bytes(), filepath.Join("testdata", "golden", tt.format+f.ext)
+			if tt.part != "" {
+				got, path = zipPart(t, got, tt.part), filepath.Join("testdata", "golden", tt.format+"-"+filepath.Base(tt.part))
+			}
+			checkGolden(t, path, got)
+		})
+	}
+}
+
+func checkGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run w/ -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s:\n%s", path, unifiedDiff(path, "got", want, got))
+	}
+}
+
+// zipPart returns the content of the named file of zip archive data
+func zipPart(t *testing.T, data []byte, name string) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := zr.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
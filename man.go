@@ -0,0 +1,501 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// manTitleRe matches titles like `rmd(1) -- render Markdown docs`, as ronn has them, or `rmd - render
+// Markdown docs`; dashes may have turned typographic already
+var manTitleRe = regexp.MustCompile(`^([\w.:+-]+?)(?:\((\w+)\))?(?:\s+(?:-{1,2}|–|—)\s+(.+))?$`)
+
+// manRequest marks requests written among text, e.g. `.br` of hard line breaks, so that manLines leaves
+// them be
+const manRequest = "\x00"
+
+// manEscaper escapes text for roff; minus signs are escaped so that options copy as typed
+var manEscaper = strings.NewReplacer(`\`, `\e`, `-`, `\-`)
+
+// renderMan renders the doc as a man page. The header comes from front matter `title` (e.g. `rmd(1)`),
+// `section`, `date`, `source` and `manual`; w/o a title, the first H1 is the title.
+func renderMan(w io.Writer, md goldmark.Markdown, doc *document) error {
+	root := md.Parser().Parse(text.NewReader(doc.source))
+	mw := &manWriter{source: doc.source, footnotes: map[int]*east.Footnote{}, noteNums: map[int]int{}}
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch n := n.(type) {
+		case *east.Footnote:
+			mw.footnotes[n.Index] = n
+		case *east.Table, *csvTable:
+			mw.tables = true
+		}
+		return ast.WalkContinue, nil
+	})
+	title := doc.meta["title"]
+	if title == "" {
+		for c := root.FirstChild(); c != nil; c = c.NextSibling() {
+			if h, ok := c.(*ast.Heading); ok && h.Level == 1 {
+				mw.title, title = h, plainText(h, doc.source)
+				break
+			}
+		}
+	}
+	if title == "" && doc.path != "-" {
+		title = strings.TrimSuffix(filepath.Base(doc.path), filepath.Ext(doc.path))
+	}
+	name, section, description := title, doc.meta["section"], ""
+	if m := manTitleRe.FindStringSubmatch(title); m != nil {
+		name, description = m[1], m[3]
+		if section == "" {
+			section = m[2]
+		}
+	}
+	if section == "" {
+		section = "1"
+	}
+	// sections of the page are the headings of the top level present, subsections the level below
+	mw.sectionLevel = 6
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if h, ok := n.(*ast.Heading); ok && entering && n != mw.title {
+			mw.sectionLevel = min(mw.sectionLevel, h.Level)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	out := &mw.out
+	if mw.tables {
+		// tells man to run tbl
+		out.WriteString("'\\\" t\n")
+	}
+	fmt.Fprintf(out, ".TH %s %s %s %s %s\n", manQuote(strings.ToUpper(name)), manQuote(section), manQuote(doc.meta["date"]),
+		manQuote(doc.meta["source"]), manQuote(doc.meta["manual"]))
+	if description != "" {
+		out.WriteString(".SH NAME\n" + manLines(manEscaper.Replace(name)+` \- `+manEscaper.Replace(description)) + "\n")
+	}
+	mw.blocks(root)
+	if len(mw.notes) > 0 {
+		out.WriteString(".SH NOTES\n")
+		for i, note := range mw.notes {
+			fmt.Fprintf(out, ".IP [%d] 5\n%s\n", i+1, note)
+		}
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// manQuote quotes an argument of a macro
+func manQuote(s string) string {
+	return `"` + strings.ReplaceAll(manEscaper.Replace(s), `"`, `\(dq`) + `"`
+}
+
+// manLines makes text safe to write as lines of a paragraph: lines must not start w/ a control
+// character nor spaces, which would break them
+func manLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		l = strings.TrimLeft(l, " \t")
+		if req, ok := strings.CutPrefix(l, manRequest); ok {
+			l = req
+		} else if strings.HasPrefix(l, ".") || strings.HasPrefix(l, "'") {
+			l = `\&` + l
+		}
+		lines[i] = l
+	}
+	return strings.Join(lines, "\n")
+}
+
+// manWriter converts a goldmark AST to roff w/ the man macros
+type manWriter struct {
+	source []byte
+	out    strings.Builder
+	// footnote definitions by index, footnotes referenced so far in order, and their numbers by index
+	footnotes map[int]*east.Footnote
+	notes     []string
+	noteNums  map[int]int
+	// whether the doc has tables, which need tbl
+	tables bool
+	// heading the page title comes from, if any
+	title ast.Node
+	// heading level of sections
+	sectionLevel int
+	// font in effect: R, I, B or BI
+	font string
+}
+
+func (w *manWriter) blocks(parent ast.Node) {
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		w.block(c)
+	}
+}
+
+func (w *manWriter) block(n ast.Node) {
+	out := &w.out
+	switch n := n.(type) {
+	case *ast.Heading:
+		if n == w.title {
+			return
+		}
+		switch n.Level - w.sectionLevel {
+		case 0:
+			out.WriteString(".SH " + manQuote(strings.ToUpper(plainText(n, w.source))) + "\n")
+		case 1:
+			out.WriteString(".SS " + manQuote(plainText(n, w.source)) + "\n")
+		default:
+			out.WriteString(".PP\n" + manLines(`\fB`+manEscaper.Replace(plainText(n, w.source))+`\fR`) + "\n")
+		}
+	case *ast.Paragraph, *ast.TextBlock:
+		out.WriteString(".PP\n" + w.inlines(n) + "\n")
+	case *ast.ThematicBreak:
+		out.WriteString(".PP\n\\l'\\n(.lu'\n")
+	case *ast.CodeBlock, *ast.FencedCodeBlock, *mathBlock:
+		var b strings.Builder
+		for i := 0; i < n.Lines().Len(); i++ {
+			seg := n.Lines().At(i)
+			line := manEscaper.Replace(strings.TrimRight(string(seg.Value(w.source)), "\r\n"))
+			if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+				line = `\&` + line
+			}
+			b.WriteString(line + "\n")
+		}
+		out.WriteString(".PP\n.RS 4\n.nf\n" + b.String() + ".fi\n.RE\n")
+	case *ast.Blockquote:
+		out.WriteString(".RS 4\n")
+		w.blocks(n)
+		out.WriteString(".RE\n")
+	case *ast.List:
+		w.list(n)
+	case *east.DefinitionList:
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			if _, ok := c.(*east.DefinitionTerm); ok {
+				out.WriteString(".TP\n" + w.inlines(c) + "\n")
+				continue
+			}
+			w.itemBlocks(c)
+		}
+	case *east.Table:
+		var rows [][]string
+		for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+			var cells []string
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, w.inlines(cell))
+			}
+			rows = append(rows, cells)
+		}
+		var aligns []string
+		for _, a := range n.Alignments {
+			switch a {
+			case east.AlignCenter:
+				aligns = append(aligns, "c")
+			case east.AlignRight:
+				aligns = append(aligns, "r")
+			default:
+				aligns = append(aligns, "l")
+			}
+		}
+		w.table(aligns, rows, true, 0)
+	case *csvTable:
+		columns := 0
+		for _, row := range n.rows {
+			columns = max(columns, len(row))
+		}
+		aligns := make([]string, columns)
+		for i := range aligns {
+			aligns[i] = "l"
+		}
+		rows := make([][]string, len(n.rows))
+		for i, row := range n.rows {
+			for _, v := range row {
+				rows[i] = append(rows[i], manEscaper.Replace(v))
+			}
+		}
+		w.table(aligns, rows, n.header, n.omitted)
+	case *containerBlock:
+		title := n.title
+		if title == "" {
+			title = strings.ToUpper(n.name[:1]) + n.name[1:]
+		}
+		out.WriteString(".PP\n" + manLines(`\fB`+manEscaper.Replace(title)+`\fR`) + "\n.RS 4\n")
+		w.blocks(n)
+		out.WriteString(".RE\n")
+	case *figureBlock:
+		out.WriteString(".PP\n" + w.inlines(n) + "\n")
+		if len(n.caption) > 0 {
+			out.WriteString(".br\n" + manLines(`\fI`+manEscaper.Replace(string(n.caption))+`\fR`) + "\n")
+		}
+	case *ast.HTMLBlock, *east.FootnoteList:
+		// raw html means nothing to roff; footnotes go to the NOTES section
+	default:
+		w.blocks(n)
+	}
+}
+
+func (w *manWriter) list(n *ast.List) {
+	out := &w.out
+	nested := false
+	for p := n.Parent(); p != nil; p = p.Parent() {
+		if p.Kind() == ast.KindListItem {
+			nested = true
+			break
+		}
+	}
+	if nested {
+		out.WriteString(".RS\n")
+	}
+	terms := manListTerms(n)
+	num := n.Start
+	for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+		switch {
+		case terms != nil:
+			// options lists like `- `-v`, `--verbose`:` followed by a description
+			p := item.FirstChild()
+			var term, desc strings.Builder
+			cur := &term
+			for c := p.FirstChild(); c != nil; c = c.NextSibling() {
+				if c == terms[item] {
+					// text ending the term line is part of the term, w/o a colon ending it
+					term.WriteString(strings.TrimSuffix(strings.TrimRight(w.inline(c), "\n"), ":"))
+					cur = &desc
+					continue
+				}
+				cur.WriteString(w.inline(c))
+			}
+			out.WriteString(".TP\n" + manLines(term.String()) + "\n" + manLines(strings.TrimRight(desc.String(), "\n")) + "\n")
+			for c := p.NextSibling(); c != nil; c = c.NextSibling() {
+				w.itemBlock(c)
+			}
+			continue
+		case n.IsOrdered():
+			fmt.Fprintf(out, ".IP %d. 4\n", num)
+			num++
+		default:
+			out.WriteString(".IP \\(bu 2\n")
+		}
+		w.itemBlocks(item)
+	}
+	if nested {
+		out.WriteString(".RE\n")
+	}
+}
+
+// manListTerms tells whether every item of an unordered list starts w/ a term line, ronn style, e.g. a
+// line of "`-v`, `--verbose`:" followed by lines describing the option. It returns the Text nodes ending
+// the term lines by item, or nil if the list is a plain one.
+func manListTerms(n *ast.List) map[ast.Node]ast.Node {
+	if n.IsOrdered() {
+		return nil
+	}
+	terms := map[ast.Node]ast.Node{}
+	for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+		p := item.FirstChild()
+		if p == nil || (p.Kind() != ast.KindParagraph && p.Kind() != ast.KindTextBlock) {
+			return nil
+		}
+		if first := p.FirstChild(); first == nil || (first.Kind() != ast.KindCodeSpan && first.Kind() != ast.KindEmphasis) {
+			return nil
+		}
+		var end ast.Node
+		for c := p.FirstChild(); c != nil && end == nil; c = c.NextSibling() {
+			if t, ok := c.(*ast.Text); ok && (t.SoftLineBreak() || t.HardLineBreak()) {
+				end = t
+			}
+		}
+		if end == nil || end.NextSibling() == nil {
+			return nil
+		}
+		terms[item] = end
+	}
+	return terms
+}
+
+// itemBlocks writes the blocks of a list item or definition; paragraphs after the first one are
+// indented along
+func (w *manWriter) itemBlocks(parent ast.Node) {
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		if c == parent.FirstChild() && (c.Kind() == ast.KindParagraph || c.Kind() == ast.KindTextBlock) {
+			w.out.WriteString(w.inlines(c) + "\n")
+			continue
+		}
+		w.itemBlock(c)
+	}
+}
+
+func (w *manWriter) itemBlock(n ast.Node) {
+	switch n.Kind() {
+	case ast.KindParagraph, ast.KindTextBlock:
+		w.out.WriteString(".IP\n" + w.inlines(n) + "\n")
+	case ast.KindList:
+		w.block(n)
+	default:
+		w.out.WriteString(".RS\n")
+		w.block(n)
+		w.out.WriteString(".RE\n")
+	}
+}
+
+// table writes rows as a tbl table, w/ columns aligned per aligns
+func (w *manWriter) table(aligns []string, rows [][]string, header bool, omitted int) {
+	if len(aligns) == 0 {
+		return
+	}
+	out := &w.out
+	out.WriteString(".TS\ntab(\t);\n")
+	if header {
+		out.WriteString(strings.Join(aligns, "B ") + "B\n")
+	}
+	out.WriteString(strings.Join(aligns, " ") + ".\n")
+	for i, row := range rows {
+		cells := make([]string, len(aligns))
+		for j := range cells {
+			if j < len(row) {
+				// cells are text blocks so that long ones wrap
+				cells[j] = "T{\n" + manLines(strings.ReplaceAll(row[j], "\t", " ")) + "\nT}"
+			}
+		}
+		out.WriteString(strings.Join(cells, "\t") + "\n")
+		if i == 0 && header {
+			out.WriteString("_\n")
+		}
+	}
+	if omitted > 0 {
+		more := fmt.Sprintf("%d more rows", omitted)
+		if omitted == 1 {
+			more = "1 more row"
+		}
+		out.WriteString(`\fI` + more + `\fR` + "\n")
+	}
+	out.WriteString(".TE\n")
+}
+
+// inlines returns the roff of the inline children of parent, safe to write as lines
+func (w *manWriter) inlines(parent ast.Node) string {
+	var b strings.Builder
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		b.WriteString(w.inline(c))
+	}
+	return manLines(strings.TrimRight(b.String(), "\n"))
+}
+
+// withFont returns s in given font style combined w/ the one in effect, switching back after
+func (w *manWriter) withFont(style string, s func() string) string {
+	prev := w.font
+	if prev == "" {
+		prev = "R"
+	}
+	font := style
+	switch {
+	case prev == "R":
+	case strings.Contains(prev, style):
+		font = prev
+	default:
+		font = "BI"
+	}
+	w.font = font
+	content := s()
+	w.font = prev
+	return manFont(font) + content + manFont(prev)
+}
+
+func manFont(font string) string {
+	if len(font) > 1 {
+		return `\f(` + font
+	}
+	return `\f` + font
+}
+
+func (w *manWriter) inline(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Text:
+		s := manEscaper.Replace(leafText(n, w.source))
+		if n.HardLineBreak() {
+			s += "\n" + manRequest + ".br\n"
+		} else if n.SoftLineBreak() {
+			s += "\n"
+		}
+		return s
+	case *ast.String:
+		return manEscaper.Replace(leafText(n, w.source))
+	case *ast.CodeSpan:
+		// literals are bold per man-pages(7)
+		return w.withFont("B", func() string { return manEscaper.Replace(plainText(n, w.source)) })
+	case *mathInline:
+		return w.withFont("I", func() string { return manEscaper.Replace(mathText(n, w.source)) })
+	case *ast.Emphasis:
+		style := "I"
+		if n.Level >= 2 {
+			style = "B"
+		}
+		return w.withFont(style, func() string { return w.inlinesRaw(n) })
+	case *inlineTag:
+		switch n.syntax.tag {
+		case "kbd":
+			return w.withFont("B", func() string { return w.inlinesRaw(n) })
+		case "sub":
+			return "_" + w.inlinesRaw(n)
+		case "sup":
+			return "^" + w.inlinesRaw(n)
+		}
+		return w.inlinesRaw(n)
+	case *ast.Link:
+		return w.link(w.inlinesRaw(n), string(n.Destination))
+	case *ast.AutoLink:
+		return w.withFont("I", func() string { return manEscaper.Replace(string(n.Label(w.source))) })
+	case *wikiLink:
+		return w.link(w.inlinesRaw(n), n.href)
+	case *ast.Image:
+		alt := plainText(n, w.source)
+		if alt == "" {
+			alt = string(n.Destination)
+		}
+		return "[image: " + manEscaper.Replace(alt) + "]"
+	case *east.TaskCheckBox:
+		if n.IsChecked {
+			return "[x] "
+		}
+		return "[ ] "
+	case *east.FootnoteLink:
+		fn, ok := w.footnotes[n.Index]
+		if !ok {
+			return ""
+		}
+		num, ok := w.noteNums[n.Index]
+		if !ok {
+			var body []string
+			for c := fn.FirstChild(); c != nil; c = c.NextSibling() {
+				body = append(body, w.inlines(c))
+			}
+			w.notes = append(w.notes, strings.Join(body, "\n.IP\n"))
+			num = len(w.notes)
+			w.noteNums[n.Index] = num
+		}
+		return "[" + strconv.Itoa(num) + "]"
+	case *ast.RawHTML, *east.FootnoteBacklink, *imageAttrs:
+		return ""
+	}
+	return w.inlinesRaw(n)
+}
+
+// inlinesRaw is inlines w/o making lines safe, for content within a line
+func (w *manWriter) inlinesRaw(parent ast.Node) string {
+	var b strings.Builder
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		b.WriteString(w.inline(c))
+	}
+	return b.String()
+}
+
+// link writes a link as its text followed by the URL, unless they are the same or the link stays
+// within the page
+func (w *manWriter) link(label, dest string) string {
+	if dest == "" || strings.HasPrefix(dest, "#") || manEscaper.Replace(dest) == label {
+		return label
+	}
+	return label + " (" + w.withFont("I", func() string { return manEscaper.Replace(dest) }) + ")"
+}
@@ -0,0 +1,627 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// markdownFormatter renders a goldmark AST back to Markdown in canonical form: ATX headings, `-`
+// bullets, fenced code, `*`/`**` emphasis and tables w/ aligned pipes.
+//
+// Text is copied verbatim from source so that backslash escapes and entities survive a round trip.
+type markdownFormatter struct {
+	source []byte
+	// wrap controls paragraph line breaks: < 0 keeps them as written, 0 joins every paragraph onto
+	// a single line and > 0 re-wraps paragraphs at that many columns
+	wrap int
+
+	doc ast.Node
+	// footnote labels by index, collected on demand
+	footnotes map[int][]byte
+}
+
+// minimum width left for paragraph text when re-wrapping deeply nested blocks
+const minWrapWidth = 20
+
+// Format returns the canonical Markdown text of given document node
+func (f *markdownFormatter) Format(doc ast.Node) []byte {
+	f.doc = doc
+	out := f.blocks(doc, f.wrap, false)
+	if out == "" {
+		return nil
+	}
+	return []byte(out + "\n")
+}
+
+// blocks renders the block children of given node, separating them w/ blank lines unless tight
+func (f *markdownFormatter) blocks(parent ast.Node, width int, tight bool) string {
+	var parts []string
+	var prev ast.Node
+	// alternate markers of adjacent lists, otherwise they would be merged into one after formatting
+	alt := false
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		if l, ok := c.(*ast.List); ok {
+			if pl, ok := prev.(*ast.List); ok && pl.IsOrdered() == l.IsOrdered() {
+				alt = !alt
+			} else {
+				alt = false
+			}
+		}
+		s := f.block(c, width, alt)
+		if s == "" {
+			// e.g. paragraphs which held nothing but link reference definitions
+			continue
+		}
+		// `---` right below a line of text in a tight list would turn the text into a setext heading
+		if _, ok := c.(*ast.ThematicBreak); ok && tight && prev != nil && prev.Kind() == ast.KindTextBlock {
+			s = "***"
+		}
+		parts = append(parts, s)
+		prev = c
+	}
+	sep := "\n\n"
+	if tight {
+		sep = "\n"
+	}
+	return strings.Join(parts, sep)
+}
+
+func (f *markdownFormatter) block(n ast.Node, width int, alt bool) string {
+	switch n := n.(type) {
+	case *ast.Heading:
+		// setext headings may span lines while ATX ones cannot
+		s := strings.Repeat("#", n.Level)
+		if txt := strings.TrimSpace(strings.ReplaceAll(f.inlines(n), "\n", " ")); txt != "" {
+			s += " " + txt
+		}
+		return s
+	case *ast.Paragraph, *ast.TextBlock, *figureBlock:
+		return f.paragraph(f.inlines(n), width)
+	case *csvTable:
+		return f.block(n.orig, width, alt)
+	case *linkRefDefs:
+		return f.linkRefDefs(n)
+	case *ast.ThematicBreak:
+		return "---"
+	case *ast.CodeBlock:
+		return fence("", f.lines(n))
+	case *ast.FencedCodeBlock:
+		var info string
+		if n.Info != nil {
+			info = string(n.Info.Segment.Value(f.source))
+		}
+		return fence(info, f.lines(n))
+	case *ast.Blockquote:
+		return prefixLines(f.blocks(n, width-2, false), "> ", "> ", ">")
+	case *ast.List:
+		return f.list(n, width, alt)
+	case *ast.HTMLBlock:
+		s := f.lines(n)
+		if n.HasClosure() {
+			s += string(n.ClosureLine.Value(f.source))
+		}
+		return strings.TrimRight(s, "\n")
+	case *east.Table:
+		return f.table(n)
+	case *mathBlock:
+		return "$$\n" + f.lines(n) + "$$"
+	case *containerBlock:
+		open := "::: " + n.name
+		if n.info != "" {
+			open += " " + n.info
+		}
+		if body := f.blocks(n, width, false); body != "" {
+			return open + "\n" + body + "\n:::"
+		}
+		return open + "\n:::"
+	case *east.DefinitionList:
+		return f.definitionList(n, width)
+	case *east.FootnoteList:
+		var notes []string
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			if fn, ok := c.(*east.Footnote); ok {
+				body := f.blocks(fn, width-4, false)
+				notes = append(notes, prefixLines(body, "[^"+string(fn.Ref)+"]: ", "    ", ""))
+			}
+		}
+		return strings.Join(notes, "\n\n")
+	}
+	// unknown blocks (e.g. from extensions w/o a canonical form) are kept as written
+	if n.Type() == ast.TypeBlock && n.Lines().Len() > 0 {
+		return strings.TrimRight(f.lines(n), "\n")
+	}
+	return f.blocks(n, width, false)
+}
+
+// lines concatenates the raw source lines of given block
+func (f *markdownFormatter) lines(n ast.Node) string {
+	var b strings.Builder
+	for i := 0; i < n.Lines().Len(); i++ {
+		seg := n.Lines().At(i)
+		b.Write(seg.Value(f.source))
+	}
+	return b.String()
+}
+
+func (f *markdownFormatter) list(n *ast.List, width int, alt bool) string {
+	var items []string
+	num := n.Start
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		var marker string
+		switch {
+		case n.IsOrdered() && alt:
+			marker = strconv.Itoa(num) + ") "
+			num++
+		case n.IsOrdered():
+			marker = strconv.Itoa(num) + ". "
+			num++
+		case alt:
+			marker = "* "
+		default:
+			marker = "- "
+		}
+		body := f.blocks(c, width-len(marker), n.IsTight)
+		if body == "" {
+			items = append(items, strings.TrimSpace(marker))
+			continue
+		}
+		items = append(items, prefixLines(body, marker, strings.Repeat(" ", len(marker)), ""))
+	}
+	if n.IsTight {
+		return strings.Join(items, "\n")
+	}
+	return strings.Join(items, "\n\n")
+}
+
+func (f *markdownFormatter) definitionList(n *east.DefinitionList, width int) string {
+	var b strings.Builder
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c := c.(type) {
+		case *east.DefinitionTerm:
+			if b.Len() > 0 {
+				b.WriteString("\n\n")
+			}
+			b.WriteString(strings.ReplaceAll(f.inlines(c), "\n", " "))
+		case *east.DefinitionDescription:
+			if c.IsTight {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\n\n")
+			}
+			// IsTight is about the gap between term and description; content is loose once it holds
+			// a paragraph
+			tight := true
+			for cc := c.FirstChild(); cc != nil; cc = cc.NextSibling() {
+				tight = tight && cc.Kind() != ast.KindParagraph
+			}
+			b.WriteString(prefixLines(f.blocks(c, width-2, tight), ": ", "  ", ""))
+		}
+	}
+	return b.String()
+}
+
+func (f *markdownFormatter) table(n *east.Table) string {
+	var rows [][]string
+	for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+		var cells []string
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			cells = append(cells, strings.TrimSpace(strings.ReplaceAll(f.inlines(cell), "\n", " ")))
+		}
+		rows = append(rows, cells)
+	}
+	cols := len(n.Alignments)
+	widths := make([]int, cols)
+	for i := range widths {
+		// the delimiter row needs at least 3 dashes
+		widths[i] = 3
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if i < cols {
+				widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+			}
+		}
+	}
+	var b strings.Builder
+	writeRow := func(cells []string) {
+		b.WriteString("|")
+		for i := 0; i < cols; i++ {
+			var cell string
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			b.WriteString(" " + alignCell(cell, widths[i], n.Alignments[i]) + " |")
+		}
+		b.WriteString("\n")
+	}
+	for i, row := range rows {
+		writeRow(row)
+		if i == 0 {
+			b.WriteString("|")
+			for i, a := range n.Alignments {
+				d := strings.Repeat("-", widths[i])
+				switch a {
+				case east.AlignLeft:
+					d = ":" + d[1:]
+				case east.AlignRight:
+					d = d[1:] + ":"
+				case east.AlignCenter:
+					d = ":" + d[2:] + ":"
+				}
+				b.WriteString(" " + d + " |")
+			}
+			b.WriteString("\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func alignCell(s string, width int, a east.Alignment) string {
+	pad := width - utf8.RuneCountInString(s)
+	switch a {
+	case east.AlignRight:
+		return strings.Repeat(" ", pad) + s
+	case east.AlignCenter:
+		return strings.Repeat(" ", pad/2) + s + strings.Repeat(" ", pad-pad/2)
+	}
+	return s + strings.Repeat(" ", pad)
+}
+
+// inlines renders the inline children of given node; soft line breaks are kept as "\n" and hard
+// ones as "\\\n" so that paragraph() can rearrange lines afterwards
+func (f *markdownFormatter) inlines(parent ast.Node) string {
+	var b strings.Builder
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		f.inline(&b, c)
+	}
+	return b.String()
+}
+
+func (f *markdownFormatter) inline(b *strings.Builder, n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Text:
+		b.Write(n.Segment.Value(f.source))
+		if n.HardLineBreak() {
+			b.WriteString("\\\n")
+		} else if n.SoftLineBreak() {
+			b.WriteString("\n")
+		}
+	case *ast.String:
+		b.Write(n.Value)
+	case *ast.CodeSpan:
+		var code strings.Builder
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			if t, ok := c.(*ast.Text); ok {
+				code.Write(t.Segment.Value(f.source))
+			}
+		}
+		b.WriteString(codeSpan(strings.ReplaceAll(code.String(), "\n", " ")))
+	case *ast.Emphasis:
+		m := strings.Repeat("*", n.Level)
+		b.WriteString(m + f.inlines(n) + m)
+	case *wikiLink:
+		b.WriteString("[[" + n.raw + "]]")
+	case *imageAttrs:
+		b.WriteString(n.raw)
+	case *inlineTag:
+		b.WriteString(n.syntax.delim + f.inlines(n) + n.syntax.delim)
+	case *mathInline:
+		delim := "$"
+		if n.display {
+			delim = "$$"
+		}
+		b.WriteString(delim + strings.ReplaceAll(mathText(n, f.source), "\n", " ") + delim)
+	case *east.Strikethrough:
+		b.WriteString("~~" + f.inlines(n) + "~~")
+	case *ast.Link:
+		b.WriteString("[" + f.inlines(n) + "]" + f.linkTarget(n, n.Destination, n.Title))
+	case *ast.Image:
+		b.WriteString("![" + f.inlines(n) + "]" + f.linkTarget(n, n.Destination, n.Title))
+	case *ast.AutoLink:
+		label := n.Label(f.source)
+		// keep bare (linkified) URLs bare and bracketed autolinks bracketed
+		if off := sourceOffset(f.source, label); off > 0 && f.source[off-1] == '<' {
+			b.WriteString("<" + string(label) + ">")
+		} else {
+			b.Write(label)
+		}
+	case *ast.RawHTML:
+		for i := 0; i < n.Segments.Len(); i++ {
+			seg := n.Segments.At(i)
+			b.Write(seg.Value(f.source))
+		}
+	case *east.FootnoteLink:
+		b.WriteString("[^" + string(f.footnoteRef(n.Index)) + "]")
+	case *east.FootnoteBacklink:
+		// generated by the parser, not part of the source
+	case *east.TaskCheckBox:
+		if n.IsChecked {
+			b.WriteString("[x] ")
+		} else {
+			b.WriteString("[ ] ")
+		}
+	default:
+		b.WriteString(f.inlines(n))
+	}
+}
+
+// footnoteRef looks up the label of the footnote w/ given index
+func (f *markdownFormatter) footnoteRef(index int) []byte {
+	if f.footnotes == nil {
+		f.footnotes = map[int][]byte{}
+		ast.Walk(f.doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			if fn, ok := n.(*east.Footnote); ok && entering {
+				f.footnotes[fn.Index] = fn.Ref
+			}
+			return ast.WalkContinue, nil
+		})
+	}
+	return f.footnotes[index]
+}
+
+// sourceOffset returns the offset of sub within source, given that sub is a sub-slice of source;
+// otherwise -1 is returned
+func sourceOffset(source, sub []byte) int {
+	off := cap(source) - cap(sub)
+	if off < 0 || off+len(sub) > len(source) || len(sub) == 0 || &source[off] != &sub[0] {
+		return -1
+	}
+	return off
+}
+
+// linkTarget returns the part of a link or image following its text; references like `[text][label]`,
+// `[text][]` and `[text]` stay as written so that their definitions still apply
+func (f *markdownFormatter) linkTarget(n ast.Node, dest, title []byte) string {
+	// the text ends at the first `]` after its last segment, past closing delimiters of emphasis and
+	// code; texts ending in nested links or images are left inline
+	last := n.LastChild()
+	for last != nil && last.Kind() != ast.KindText && last.Kind() != ast.KindLink && last.Kind() != ast.KindImage {
+		last = last.LastChild()
+	}
+	t, ok := last.(*ast.Text)
+	if !ok {
+		return linkTarget(dest, title)
+	}
+	i := t.Segment.Stop
+	for i < len(f.source) && strings.IndexByte("*_~`$ \t\n", f.source[i]) >= 0 {
+		i++
+	}
+	if i >= len(f.source) || f.source[i] != ']' {
+		return linkTarget(dest, title)
+	}
+	i++
+	if i < len(f.source) && f.source[i] == '(' {
+		return linkTarget(dest, title)
+	}
+	if i < len(f.source) && f.source[i] == '[' {
+		if end := bytes.IndexByte(f.source[i:], ']'); end >= 0 {
+			return string(f.source[i : i+end+1])
+		}
+	}
+	return ""
+}
+
+func codeSpan(code string) string {
+	longest, run := 0, 0
+	for _, r := range code {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	ticks := strings.Repeat("`", longest+1)
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") ||
+		(strings.HasPrefix(code, " ") && strings.HasSuffix(code, " ") && strings.TrimSpace(code) != "") {
+		code = " " + code + " "
+	}
+	return ticks + code + ticks
+}
+
+func linkTarget(dest, title []byte) string {
+	d := string(dest)
+	if d == "" || strings.ContainsAny(d, " <>") || strings.Count(d, "(") != strings.Count(d, ")") {
+		d = "<" + d + ">"
+	}
+	if len(title) > 0 {
+		d += ` "` + escapeTitleQuotes(string(title)) + `"`
+	}
+	return "(" + d + ")"
+}
+
+// escapeTitleQuotes escapes the `"` of a link title as written in source, which may have been
+// delimited otherwise; backslash escapes there already are kept as is
+func escapeTitleQuotes(title string) string {
+	var b strings.Builder
+	for i := 0; i < len(title); i++ {
+		switch c := title[i]; {
+		case c == '\\' && i+1 < len(title):
+			b.WriteString(title[i : i+2])
+			i++
+		case c == '"':
+			b.WriteString(`\"`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// fence wraps code into a fenced code block whose fence cannot clash w/ the code itself
+func fence(info, code string) string {
+	char := "`"
+	if strings.Contains(info, "`") {
+		char = "~"
+	}
+	longest := 0
+	for _, line := range strings.Split(code, "\n") {
+		t := strings.TrimLeft(line, " ")
+		if n := len(t) - len(strings.TrimLeft(t, char)); n > longest {
+			longest = n
+		}
+	}
+	f := strings.Repeat(char, max(3, longest+1))
+	if code != "" && !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+	return f + info + "\n" + code + f
+}
+
+// prefixLines prefixes the first line of s w/ first and the rest w/ rest; empty lines get blank
+// instead so that no trailing whitespace is produced
+func prefixLines(s, first, rest, blank string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		switch {
+		case i == 0:
+			lines[i] = first + line
+		case line == "":
+			lines[i] = blank
+		default:
+			lines[i] = rest + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// a line starting w/ one of these would start a new block rather than continue a paragraph
+var blockStartRe = regexp.MustCompile("^((#{1,6}|[-+*]|\\d{1,9}[.)])$|=+$|-+$|>|```|~~~|<|\\|)")
+
+// paragraph lays out paragraph text according to the wrap setting
+func (f *markdownFormatter) paragraph(s string, width int) string {
+	s = strings.TrimRight(s, "\n")
+	if f.wrap < 0 {
+		return s
+	}
+	// lines ending in a hard break must stay as they are; everything between them is one run of text
+	var out []string
+	var run []string
+	flush := func(hard string) {
+		txt := strings.Join(run, " ") + hard
+		run = nil
+		if f.wrap == 0 {
+			out = append(out, txt)
+			return
+		}
+		out = append(out, wrapText(txt, max(width, minWrapWidth))...)
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasSuffix(line, "\\") {
+			run = append(run, strings.TrimSuffix(line, "\\"))
+			flush("\\")
+			continue
+		}
+		run = append(run, line)
+	}
+	if run != nil {
+		flush("")
+	}
+	return strings.Join(out, "\n")
+}
+
+// wrapText greedily breaks s at single spaces so that lines fit in width where possible
+func wrapText(s string, width int) []string {
+	var lines []string
+	var line bytes.Buffer
+	lineLen := 0
+	for _, word := range splitWords(s) {
+		wl := utf8.RuneCountInString(word)
+		if lineLen > 0 && lineLen+1+wl > width && !blockStartRe.MatchString(word) {
+			lines = append(lines, line.String())
+			line.Reset()
+			lineLen = 0
+		}
+		if lineLen > 0 {
+			line.WriteByte(' ')
+			lineLen++
+		}
+		line.WriteString(word)
+		lineLen += wl
+	}
+	return append(lines, line.String())
+}
+
+// splitWords splits s at single spaces only: a run of spaces is kept inside a word since leading
+// whitespace of a continuation line is dropped by the parser
+func splitWords(s string) []string {
+	var words []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != ' ' || i == 0 || s[i-1] == ' ' || i+1 == len(s) || s[i+1] == ' ' {
+			continue
+		}
+		words = append(words, s[start:i])
+		start = i + 1
+	}
+	return append(words, s[start:])
+}
+
+var kindLinkRefDefs = ast.NewNodeKind("LinkReferenceDefinitions")
+
+// linkRefDefs keeps the place of link reference definitions in front of a paragraph, which goldmark
+// drops from the AST once parsed; formatting writes them back as written
+type linkRefDefs struct {
+	ast.BaseBlock
+	// lines of the paragraph before the definitions got dropped
+	lines []text.Segment
+	// offset of the first line left in the paragraph, -1 if nothing but definitions was left
+	stop int
+}
+
+func (n *linkRefDefs) Kind() ast.NodeKind {
+	return kindLinkRefDefs
+}
+
+func (n *linkRefDefs) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// linkRefDefsTransformer inserts a linkRefDefs node in front of every paragraph right before
+// goldmark's link reference transformer runs, and notes what it left right after
+type linkRefDefsTransformer struct {
+	after bool
+}
+
+func (t linkRefDefsTransformer) Transform(node *ast.Paragraph, reader text.Reader, pc parser.Context) {
+	lines := node.Lines()
+	if t.after {
+		// paragraphs of nothing but definitions are gone by now and never get here
+		defs, ok := node.PreviousSibling().(*linkRefDefs)
+		if !ok || lines.Len() == 0 {
+			return
+		}
+		if defs.stop = lines.At(0).Start; defs.stop == defs.lines[0].Start {
+			// w/o definitions the node would only get in the way of e.g. telling tight lists
+			node.Parent().RemoveChild(node.Parent(), defs)
+			return
+		}
+		node.SetBlankPreviousLines(false)
+		return
+	}
+	defs := &linkRefDefs{lines: make([]text.Segment, lines.Len()), stop: -1}
+	for i := range defs.lines {
+		defs.lines[i] = lines.At(i)
+	}
+	defs.SetBlankPreviousLines(node.HasBlankPreviousLines())
+	node.Parent().InsertBefore(node.Parent(), node, defs)
+}
+
+func (f *markdownFormatter) linkRefDefs(n *linkRefDefs) string {
+	var defs []string
+	for _, seg := range n.lines {
+		if n.stop >= 0 && seg.Start >= n.stop {
+			break
+		}
+		defs = append(defs, strings.TrimSpace(string(seg.Value(f.source))))
+	}
+	return strings.Join(defs, "\n")
+}
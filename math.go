@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"html"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	kindMath      = ast.NewNodeKind("Math")
+	kindMathBlock = ast.NewNodeKind("MathBlock")
+)
+
+// mathInline is TeX math within text, `$x^2$` or `$$\sum_i x_i$$` for display style; its children are
+// raw text segments of the TeX source, as of code spans
+type mathInline struct {
+	ast.BaseInline
+	display bool
+}
+
+func (n *mathInline) Kind() ast.NodeKind {
+	return kindMath
+}
+
+func (n *mathInline) Dump(source []byte, level int) {
+	display := "false"
+	if n.display {
+		display = "true"
+	}
+	ast.DumpHelper(n, source, level, map[string]string{"Display": display}, nil)
+}
+
+// mathBlock is display math fenced by lines of `$$`; its lines are the TeX source
+type mathBlock struct {
+	ast.BaseBlock
+}
+
+func (n *mathBlock) Kind() ast.NodeKind {
+	return kindMathBlock
+}
+
+func (n *mathBlock) IsRaw() bool {
+	return true
+}
+
+func (n *mathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// mathText returns the TeX source of a math node
+func mathText(n ast.Node, source []byte) string {
+	var b bytes.Buffer
+	if n.Kind() == kindMathBlock {
+		for i := 0; i < n.Lines().Len(); i++ {
+			seg := n.Lines().At(i)
+			b.Write(seg.Value(source))
+		}
+		return b.String()
+	}
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		if t, ok := c.(*ast.Text); ok {
+			b.Write(t.Segment.Value(source))
+		}
+	}
+	return b.String()
+}
+
+// mathSyntax is the goldmark extension of math. Math renders as html the way MathJax and KaTeX pick it
+// up, w/ `\(...\)` and `\[...\]` delimiters, and passes through as is to formats speaking TeX.
+type mathSyntax struct{}
+
+func (mathSyntax) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(mathBlockParser{}, 150)),
+		parser.WithInlineParsers(util.Prioritized(mathInlineParser{}, 150)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(mathSyntax{}, 500)))
+}
+
+type mathInlineParser struct{}
+
+func (mathInlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+// Parse takes `$...$` per Pandoc's rules: the opening `$` is followed by a non space, the closing one
+// follows a non space and is not followed by a digit, so that prices like $5 and $10 stay text
+func (mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	opener := 1
+	if len(line) > 1 && line[1] == '$' {
+		opener = 2
+	}
+	if len(line) <= opener || (opener == 1 && util.IsSpace(line[1])) {
+		return nil
+	}
+	l, pos := block.Position()
+	block.Advance(opener)
+	node := &mathInline{display: opener == 2}
+	for {
+		line, segment := block.PeekLine()
+		if line == nil {
+			block.SetPosition(l, pos)
+			return nil
+		}
+		for i := 0; i < len(line); i++ {
+			switch {
+			case line[i] == '\\':
+				i++
+			case line[i] == '$':
+				closer := 1
+				if i+1 < len(line) && line[i+1] == '$' {
+					closer = 2
+				}
+				if closer != opener {
+					// `$$` within `$` math is ambiguous, so neither is math
+					if opener == 1 {
+						block.SetPosition(l, pos)
+						return nil
+					}
+					continue
+				}
+				if opener == 1 && (i == 0 || util.IsSpace(line[i-1]) || i+1 < len(line) && util.IsNumeric(line[i+1])) {
+					continue
+				}
+				if i > 0 {
+					node.AppendChild(node, ast.NewRawTextSegment(segment.WithStop(segment.Start+i)))
+				}
+				if node.ChildCount() == 0 {
+					block.SetPosition(l, pos)
+					return nil
+				}
+				block.Advance(i + closer)
+				return node
+			}
+		}
+		node.AppendChild(node, ast.NewRawTextSegment(segment))
+		block.AdvanceLine()
+	}
+}
+
+type mathBlockParser struct{}
+
+func (mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	if !isMathFence(line) {
+		return nil, parser.NoChildren
+	}
+	reader.Advance(len(bytes.TrimRight(line, "\r\n")))
+	return &mathBlock{}, parser.NoChildren
+}
+
+func (mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, segment := reader.PeekLine()
+	if line == nil {
+		return parser.Close
+	}
+	if isMathFence(line) {
+		reader.Advance(len(bytes.TrimRight(line, "\r\n")))
+		return parser.Close
+	}
+	node.Lines().Append(segment)
+	reader.Advance(len(bytes.TrimRight(line, "\r\n")))
+	return parser.Continue | parser.NoChildren
+}
+
+func (mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (mathBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// isMathFence tells whether line is a `$$` fence of a math block, indented by less than 4 spaces
+func isMathFence(line []byte) bool {
+	return string(bytes.TrimSpace(line)) == "$$" && len(line)-len(bytes.TrimLeft(line, " ")) < 4
+}
+
+func (mathSyntax) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMath, renderMath)
+	reg.Register(kindMathBlock, renderMath)
+}
+
+func renderMath(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	tex := html.EscapeString(mathText(node, source))
+	switch n := node.(type) {
+	case *mathBlock:
+		_, _ = w.WriteString("<div class=\"math math-display\">\\[\n" + tex + "\\]</div>\n")
+	case *mathInline:
+		if n.display {
+			_, _ = w.WriteString("<span class=\"math math-display\">\\[" + tex + "\\]</span>")
+		} else {
+			_, _ = w.WriteString("<span class=\"math math-inline\">\\(" + tex + "\\)</span>")
+		}
+	}
+	return ast.WalkSkipChildren, nil
+}
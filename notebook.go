@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+)
+
+// notebook is a Jupyter notebook per nbformat 4, w/ only the parts rmd renders
+type notebook struct {
+	NBFormat int `json:"nbformat"`
+	Metadata struct {
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+		KernelSpec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+	} `json:"metadata"`
+	Cells []notebookCell `json:"cells"`
+}
+
+type notebookCell struct {
+	CellType       string       `json:"cell_type"`
+	Source         notebookText `json:"source"`
+	ExecutionCount *int         `json:"execution_count"`
+	Outputs        []struct {
+		OutputType string `json:"output_type"`
+		// stream name: stdout or stderr
+		Name string       `json:"name"`
+		Text notebookText `json:"text"`
+		// mime type -> content of execute_result and display_data
+		Data      map[string]notebookText `json:"data"`
+		EName     string                  `json:"ename"`
+		EValue    string                  `json:"evalue"`
+		Traceback []string                `json:"traceback"`
+	} `json:"outputs"`
+	// file name -> mime type -> base64 data of images markdown cells refer to as `attachment:name`
+	Attachments map[string]map[string]notebookText `json:"attachments"`
+}
+
+// notebookText is multiline text, stored either as 1 string or as a list of lines
+type notebookText string
+
+func (t *notebookText) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*t = notebookText(strings.Join(lines, ""))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*t = notebookText(s)
+	return nil
+}
+
+// output mime types in order of preference, as Jupyter picks them
+var notebookMimeTypes = []string{"text/html", "image/svg+xml", "image/png", "image/jpeg", "image/gif", "text/markdown", "text/plain"}
+
+// ansiEscapeRe matches terminal color codes, which tracebacks are full of
+var ansiEscapeRe = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// notebookCSS styles cells on top of the GitHub style
+const notebookCSS = `
+.markdown-body .nb-cell {
+  margin-bottom: 16px;
+}
+
+.markdown-body .nb-prompt {
+  font-family: ui-monospace, SFMono-Regular, SF Mono, Menlo, Consolas, Liberation Mono, monospace;
+  font-size: 12px;
+  color: #59636e;
+}
+
+.markdown-body .nb-output {
+  padding-left: 1rem;
+  overflow: auto;
+  border-left: 3px solid #d1d9e0;
+}
+
+.markdown-body .nb-output pre {
+  background-color: transparent;
+}
+
+.markdown-body .nb-output .nb-stderr,
+.markdown-body .nb-output .nb-error {
+  background-color: #ffebe9;
+}
+`
+
+// renderNotebook writes the cells of a notebook as html: markdown cells go through md, code cells and
+// their outputs are rendered here. Html outputs are sanitized per policy, or passed through as is w/o
+// one.
+func renderNotebook(w io.Writer, md goldmark.Markdown, src []byte, policy *htmlPolicy) error {
+	var nb notebook
+	if err := json.Unmarshal(src, &nb); err != nil {
+		return fmt.Errorf("error parsing notebook: %w", err)
+	}
+	if nb.NBFormat < 4 {
+		return fmt.Errorf("unsupported notebook format %d, expect 4 or later", nb.NBFormat)
+	}
+	lang := nb.Metadata.LanguageInfo.Name
+	if lang == "" {
+		lang = nb.Metadata.KernelSpec.Language
+	}
+	bw := bufio.NewWriter(w)
+	for _, cell := range nb.Cells {
+		var err error
+		switch cell.CellType {
+		case "markdown":
+			err = renderMarkdownCell(bw, md, cell)
+		case "code":
+			err = renderCodeCell(bw, md, cell, lang, policy)
+		}
+		// raw cells are meant for other converters and show nothing
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func renderMarkdownCell(w *bufio.Writer, md goldmark.Markdown, cell notebookCell) error {
+	src := string(cell.Source)
+	// images attached to the cell become data URLs
+	for name, data := range cell.Attachments {
+		for mime, b64 := range data {
+			src = strings.ReplaceAll(src, "(attachment:"+name, "(data:"+mime+";base64,"+strings.TrimSpace(string(b64)))
+		}
+	}
+	_, _ = w.WriteString("<div class=\"nb-cell nb-markdown\">\n")
+	if err := md.Convert([]byte(src), w); err != nil {
+		return fmt.Errorf("error rendering markdown cell: %w", err)
+	}
+	_, _ = w.WriteString("</div>\n")
+	return nil
+}
+
+func renderCodeCell(w *bufio.Writer, md goldmark.Markdown, cell notebookCell, lang string, policy *htmlPolicy) error {
+	_, _ = w.WriteString("<div class=\"nb-cell nb-code\">\n")
+	if cell.ExecutionCount != nil {
+		fmt.Fprintf(w, "<div class=\"nb-prompt\">In [%d]:</div>\n", *cell.ExecutionCount)
+	}
+	// as a fenced code block of the notebook's language, so that code renders the same as in docs
+	if f := strings.Fields(lang); len(f) > 0 {
+		lang = f[0]
+	}
+	if err := md.Convert([]byte(fence(lang, string(cell.Source))), w); err != nil {
+		return fmt.Errorf("error rendering code cell: %w", err)
+	}
+
+	if len(cell.Outputs) > 0 {
+		_, _ = w.WriteString("<div class=\"nb-output\">\n")
+	}
+	for _, out := range cell.Outputs {
+		switch out.OutputType {
+		case "stream":
+			fmt.Fprintf(w, "<pre class=\"nb-%s\">%s</pre>\n", html.EscapeString(out.Name), html.EscapeString(string(out.Text)))
+		case "error":
+			trace := strings.Join(out.Traceback, "\n")
+			if trace == "" {
+				trace = out.EName + ": " + out.EValue
+			}
+			fmt.Fprintf(w, "<pre class=\"nb-error\">%s</pre>\n", html.EscapeString(ansiEscapeRe.ReplaceAllString(trace, "")))
+		case "execute_result", "display_data":
+			if err := renderNotebookData(w, md, out.Data, policy); err != nil {
+				return err
+			}
+		}
+	}
+	if len(cell.Outputs) > 0 {
+		_, _ = w.WriteString("</div>\n")
+	}
+	_, _ = w.WriteString("</div>\n")
+	return nil
+}
+
+// renderNotebookData renders the preferred representation of rich output
+func renderNotebookData(w *bufio.Writer, md goldmark.Markdown, data map[string]notebookText, policy *htmlPolicy) error {
+	for _, mime := range notebookMimeTypes {
+		content, ok := data[mime]
+		if !ok {
+			continue
+		}
+		switch mime {
+		case "text/html":
+			out := []byte(content)
+			if policy != nil {
+				out = policy.sanitize(out, false)
+			}
+			_, _ = w.WriteString("<div class=\"nb-html\">\n")
+			_, _ = w.Write(out)
+			_, _ = w.WriteString("\n</div>\n")
+		case "image/svg+xml":
+			// as an image rather than inline, so that scripts it may carry never run
+			b64 := base64.StdEncoding.EncodeToString([]byte(content))
+			fmt.Fprintf(w, "<img src=\"data:%s;base64,%s\" alt=\"output\">\n", mime, b64)
+		case "image/png", "image/jpeg", "image/gif":
+			b64 := strings.Join(strings.Fields(string(content)), "")
+			fmt.Fprintf(w, "<img src=\"data:%s;base64,%s\" alt=\"output\">\n", mime, html.EscapeString(b64))
+		case "text/markdown":
+			if err := md.Convert([]byte(content), w); err != nil {
+				return fmt.Errorf("error rendering markdown output: %w", err)
+			}
+		case "text/plain":
+			fmt.Fprintf(w, "<pre>%s</pre>\n", html.EscapeString(ansiEscapeRe.ReplaceAllString(string(content), "")))
+		}
+		return nil
+	}
+	return nil
+}
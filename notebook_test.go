@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRenderNotebookCodeCells(t *testing.T) {
+	tests := []struct {
+		name, nb string
+		want     []string
+	}{
+		{
+			name: "language of the kernel",
+			nb:   `{"nbformat": 4, "metadata": {"language_info": {"name": "python"}}, "cells": [{"cell_type": "code", "execution_count": 1, "source": ["print('<hi>')\n", "x"], "outputs": []}]}`,
+			want: []string{"<div class=\"nb-prompt\">In [1]:</div>\n<pre><code class=\"language-python\">print('&lt;hi&gt;')\nx\n</code></pre>\n"},
+		},
+		{
+			name: "fences within code",
+			nb:   `{"nbformat": 4, "metadata": {"kernelspec": {"language": "markdown"}}, "cells": [{"cell_type": "code", "source": "~~~\n` + "```" + `\ncode\n` + "```" + `", "outputs": []}]}`,
+			want: []string{"<pre><code class=\"language-markdown\">~~~\n```\ncode\n```\n</code></pre>\n"},
+		},
+		{
+			name: "w/o language",
+			nb:   `{"nbformat": 4, "metadata": {}, "cells": [{"cell_type": "code", "source": "1 < 2", "outputs": []}]}`,
+			want: []string{"<pre><code>1 &lt; 2\n</code></pre>\n"},
+		},
+	}
+	md, err := markdownFor(markdownOptions{profile: "rmd-extended"}, nil, &config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := renderNotebook(&out, md, []byte(tt.nb), nil); err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(out.String(), s) {
+					t.Errorf("html lacks %q:\n%s", s, out.String())
+				}
+			}
+		})
+	}
+}
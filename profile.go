@@ -0,0 +1,39 @@
+package main
+
+// profile bundles the extensions of a Markdown dialect, so that output matches the place a doc gets
+// published to
+type profile struct {
+	// names of enabled entries of markdownExtensions
+	extensions []string
+}
+
+// profile used when neither flag, front matter nor config picks one; it keeps rmd's original
+// behavior of rendering like GitHub comments do
+const defaultProfile = "gfm-comment"
+
+var profiles = map[string]profile{
+	// plain CommonMark w/o any extension
+	"commonmark": {},
+	// GitHub flavored docs e.g. READMEs, where single newlines do not break lines
+	"gfm-doc": {
+		extensions: gfmExtensions,
+	},
+	// GitHub issue and PR comments, where every newline is a line break
+	"gfm-comment": {
+		extensions: append([]string{"hardwraps"}, gfmExtensions...),
+	},
+	// GFM plus everything else rmd knows about
+	"rmd-extended": {
+		extensions: append([]string{"footnote", "deflist", "typographer", "attribute", "autoheadingid", "mark", "sub", "sup", "kbd", "container", "math", "codemeta", "figure", "csv", "wikilink"}, gfmExtensions...),
+	},
+}
+
+// resolveProfile picks the profile name by precedence: flag, front matter then config
+func resolveProfile(flagValue string, meta map[string]string, cfg *config) string {
+	for _, name := range []string{flagValue, meta["profile"], cfg.Profile} {
+		if name != "" {
+			return name
+		}
+	}
+	return defaultProfile
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestResolveProfile(t *testing.T) {
+	tests := []struct {
+		name, flag, meta, cfg, want string
+	}{
+		{name: "default", want: defaultProfile},
+		{name: "config", cfg: "gfm-doc", want: "gfm-doc"},
+		{name: "front matter over config", meta: "commonmark", cfg: "gfm-doc", want: "commonmark"},
+		{name: "flag over front matter", flag: "rmd-extended", meta: "commonmark", cfg: "gfm-doc", want: "rmd-extended"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveProfile(tt.flag, map[string]string{"profile": tt.meta}, &config{Profile: tt.cfg})
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	const md = "a\nb ~~c~~\n\n| x |\n| - |\n| 1 |\n"
+	tests := []struct {
+		profile string
+		want    []string
+		notWant []string
+	}{
+		{profile: "commonmark", want: []string{"a\nb ~~c~~"}, notWant: []string{"<br>", "<table>"}},
+		{profile: "gfm-doc", want: []string{"a\nb <del>c</del>", "<table>"}, notWant: []string{"<br>"}},
+		{profile: "gfm-comment", want: []string{"a<br>\nb <del>c</del>", "<table>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			m, err := markdownFor(markdownOptions{profile: tt.profile}, nil, &config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := m.Convert([]byte(md), &out); err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(out.String(), s) {
+					t.Errorf("missing %q in:\n%s", s, out.String())
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(out.String(), s) {
+					t.Errorf("unexpected %q in:\n%s", s, out.String())
+				}
+			}
+		})
+	}
+	if _, err := markdownFor(markdownOptions{profile: "nope"}, nil, &config{}); err == nil {
+		t.Error("want an error for an unknown profile")
+	}
+}
+
+func TestLoadDocumentProfile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, configFileName), []byte(`{"profile": "commonmark"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, md, flag string
+		want           []string
+	}{
+		{name: "config", md: "body\n", want: nil},
+		{name: "front matter", md: "---\nprofile: gfm-doc\n---\nbody\n", want: gfmExtensions},
+		{name: "flag", md: "---\nprofile: gfm-doc\n---\nbody\n", flag: "gfm-comment", want: append([]string{"hardwraps"}, gfmExtensions...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "doc.md")
+			if err := os.WriteFile(path, []byte(tt.md), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			doc, err := loadDocument(path, markdownOptions{profile: tt.flag}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append([]string(nil), tt.want...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(doc.extensions, want) {
+				t.Errorf("got extensions %v, want %v", doc.extensions, want)
+			}
+		})
+	}
+}
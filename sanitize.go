@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// ways raw html in docs gets rendered
+const (
+	// drop it, leaving `<!-- raw HTML omitted -->`, as goldmark does by default
+	htmlOmit = "omit"
+	// pass it through as is; only for trusted docs
+	htmlUnsafe = "unsafe"
+	// pass through allowlisted tags and attributes only
+	htmlSanitize = "sanitize"
+)
+
+// resolveHTMLMode picks the raw html mode by precedence: flag then config
+func resolveHTMLMode(flagValue string, cfg *config) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if cfg.HTML.Mode != "" {
+		return cfg.HTML.Mode
+	}
+	return htmlOmit
+}
+
+// allowlist of the sanitize mode: tag -> attributes, similar to what GitHub keeps
+var defaultHTMLAllowlist = map[string][]string{
+	"a": {"href", "name"}, "abbr": nil, "b": nil, "bdo": nil, "blockquote": {"cite"}, "br": nil,
+	"caption": nil, "center": nil, "cite": nil, "code": nil, "dd": nil, "del": {"cite", "datetime"},
+	"details": {"open"}, "dfn": nil, "div": nil, "dl": nil, "dt": nil, "em": nil, "figcaption": nil,
+	"figure": nil, "h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil, "hr": nil, "i": nil,
+	"img": {"src", "alt", "width", "height", "longdesc", "loading"}, "ins": {"cite", "datetime"},
+	"kbd": nil, "li": nil, "mark": nil, "ol": {"start", "reversed", "type"}, "p": nil,
+	"picture": nil, "pre": nil, "q": {"cite"}, "rp": nil, "rt": nil, "ruby": nil, "s": nil,
+	"samp": nil, "small": nil, "source": {"srcset", "media", "type", "width", "height"}, "span": nil,
+	"strike": nil, "strong": nil, "sub": nil, "summary": nil, "sup": nil,
+	"table": {"border", "cellpadding", "cellspacing", "width"}, "tbody": nil,
+	"td": {"colspan", "rowspan", "width"}, "tfoot": nil, "th": {"colspan", "rowspan", "scope", "width"},
+	"thead": nil, "tr": nil, "tt": nil, "u": nil, "ul": nil, "var": nil, "wbr": nil,
+}
+
+// attributes allowed on every allowlisted tag
+var globalHTMLAttrs = []string{"align", "dir", "id", "lang", "title"}
+
+// elements whose content goes away along w/ them
+var droppedHTMLContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true, "template": true,
+	"noscript": true, "textarea": true, "title": true, "xmp": true, "noembed": true, "noframes": true,
+}
+
+// elements w/o content or end tag, which XHTML writes as e.g. `<br />`
+var voidHTMLElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// attributes holding URLs, which must not smuggle in scripts
+var urlHTMLAttrs = map[string]bool{
+	"href": true, "src": true, "cite": true, "longdesc": true, "action": true,
+	"formaction": true, "poster": true, "background": true,
+}
+
+var (
+	htmlTagRe  = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9-]*)((?:\s+[^\s"'>/=]+(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?)*)\s*(/?)>`)
+	htmlAttrRe = regexp.MustCompile(`([^\s"'>/=]+)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?`)
+	urlSafeRe  = regexp.MustCompile(`^(?i)(?:https?:|mailto:|tel:|data:image/(?:png|gif|jpeg|webp);|[^:]*(?:[/?#]|$))`)
+)
+
+// htmlPolicy is an allowlist of html tags and their attributes
+type htmlPolicy struct {
+	// tag -> allowed attributes
+	tags map[string]map[string]bool
+}
+
+// newHTMLPolicy returns the default allowlist extended w/ allow and w/o the tags of deny
+func newHTMLPolicy(allow map[string][]string, deny []string) *htmlPolicy {
+	p := &htmlPolicy{tags: map[string]map[string]bool{}}
+	add := func(list map[string][]string) {
+		for tag, attrs := range list {
+			tag = strings.ToLower(tag)
+			if p.tags[tag] == nil {
+				p.tags[tag] = map[string]bool{}
+				for _, a := range globalHTMLAttrs {
+					p.tags[tag][a] = true
+				}
+			}
+			for _, a := range attrs {
+				p.tags[tag][strings.ToLower(a)] = true
+			}
+		}
+	}
+	add(defaultHTMLAllowlist)
+	add(allow)
+	for _, tag := range deny {
+		delete(p.tags, strings.ToLower(tag))
+	}
+	return p
+}
+
+// sanitize keeps allowlisted tags and attributes of s, drops everything else including comments,
+// scripts and styles, and escapes stray `<`; w/ xhtml, void elements are self-closed and attributes
+// always get a value, as XML requires
+func (p *htmlPolicy) sanitize(s []byte, xhtml bool) []byte {
+	var out bytes.Buffer
+	// name of the element whose content is being dropped
+	dropping := ""
+	for len(s) > 0 {
+		lt := bytes.IndexByte(s, '<')
+		if lt < 0 {
+			if dropping == "" {
+				out.Write(s)
+			}
+			break
+		}
+		if dropping == "" {
+			out.Write(s[:lt])
+		}
+		s = s[lt:]
+		switch {
+		case bytes.HasPrefix(s, []byte("<!--")):
+			end := bytes.Index(s, []byte("-->"))
+			if end < 0 {
+				return out.Bytes()
+			}
+			s = s[end+3:]
+			continue
+		case bytes.HasPrefix(s, []byte("<!")), bytes.HasPrefix(s, []byte("<?")):
+			end := bytes.IndexByte(s, '>')
+			if end < 0 {
+				return out.Bytes()
+			}
+			s = s[end+1:]
+			continue
+		}
+		m := htmlTagRe.FindSubmatch(s)
+		if m == nil {
+			if dropping == "" {
+				out.WriteString("&lt;")
+			}
+			s = s[1:]
+			continue
+		}
+		s = s[len(m[0]):]
+		closing, name := len(m[1]) > 0, strings.ToLower(string(m[2]))
+		if dropping != "" {
+			if closing && name == dropping {
+				dropping = ""
+			}
+			continue
+		}
+		if droppedHTMLContent[name] {
+			if !closing && len(m[4]) == 0 {
+				dropping = name
+			}
+			continue
+		}
+		attrs, ok := p.tags[name]
+		if !ok {
+			continue
+		}
+		if closing {
+			if !xhtml || !voidHTMLElements[name] {
+				out.WriteString("</" + name + ">")
+			}
+			continue
+		}
+		out.WriteString("<" + name)
+		for _, am := range htmlAttrRe.FindAllSubmatch(m[3], -1) {
+			attr := strings.ToLower(string(am[1]))
+			if !attrs[attr] || strings.HasPrefix(attr, "on") {
+				continue
+			}
+			val := string(am[2])
+			if len(val) >= 2 && (val[0] == '"' || val[0] == '\'') {
+				val = val[1 : len(val)-1]
+			}
+			val = html.UnescapeString(val)
+			if urlHTMLAttrs[attr] && !safeURL(val) || attr == "srcset" && !safeSrcset(val) {
+				continue
+			}
+			if len(am[2]) == 0 && xhtml {
+				out.WriteString(" " + attr + `="` + attr + `"`)
+			} else if len(am[2]) == 0 {
+				out.WriteString(" " + attr)
+			} else {
+				out.WriteString(" " + attr + `="` + html.EscapeString(val) + `"`)
+			}
+		}
+		if len(m[4]) > 0 || xhtml && voidHTMLElements[name] {
+			out.WriteString(" /")
+		}
+		out.WriteString(">")
+	}
+	return out.Bytes()
+}
+
+// safeURL tells whether u uses a scheme which cannot run scripts
+func safeURL(u string) bool {
+	// browsers ignore whitespace and control characters within schemes
+	u = strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, u)
+	return urlSafeRe.MatchString(u)
+}
+
+// safeSrcset tells whether all image candidates of a srcset are safe URLs
+func safeSrcset(srcset string) bool {
+	for _, candidate := range strings.Split(srcset, ",") {
+		if f := strings.Fields(candidate); len(f) > 0 && !safeURL(f[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sanitizingHTMLRenderer renders raw html of docs filtered by a htmlPolicy
+type sanitizingHTMLRenderer struct {
+	// goldmark's html options, for XHTML output
+	gmhtml.Config
+	policy *htmlPolicy
+}
+
+func (r *sanitizingHTMLRenderer) SetOption(name renderer.OptionName, value any) {
+	r.Config.SetOption(name, value)
+}
+
+func (r *sanitizingHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindRawHTML, r.renderRawHTML)
+	reg.Register(ast.KindHTMLBlock, r.renderHTMLBlock)
+}
+
+func (r *sanitizingHTMLRenderer) renderRawHTML(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+	n := node.(*ast.RawHTML)
+	var raw []byte
+	for i := 0; i < n.Segments.Len(); i++ {
+		seg := n.Segments.At(i)
+		raw = append(raw, seg.Value(source)...)
+	}
+	_, _ = w.Write(r.policy.sanitize(raw, r.XHTML))
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *sanitizingHTMLRenderer) renderHTMLBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.HTMLBlock)
+	var raw []byte
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		raw = append(raw, line.Value(source)...)
+	}
+	if n.HasClosure() {
+		raw = append(raw, n.ClosureLine.Value(source)...)
+	}
+	_, _ = w.Write(r.policy.sanitize(raw, r.XHTML))
+	return ast.WalkContinue, nil
+}
+
+// safeURLTransformer drops destinations of Markdown links, images and autolinks which could run
+// scripts, as sanitizing raw html alone leaves them be; goldmark's own check misses e.g. schemes
+// written w/ character references
+type safeURLTransformer struct{}
+
+func (safeURLTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	var autolinks []*ast.AutoLink
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Link:
+			if !safeURL(html.UnescapeString(string(n.Destination))) {
+				n.Destination = nil
+			}
+		case *ast.Image:
+			if !safeURL(html.UnescapeString(string(n.Destination))) {
+				n.Destination = nil
+			}
+		case *ast.AutoLink:
+			if n.AutoLinkType == ast.AutoLinkURL && !safeURL(string(n.URL(source))) {
+				autolinks = append(autolinks, n)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	// autolinks take their URL from source, so unsafe ones become links w/o destination
+	for _, n := range autolinks {
+		link := ast.NewLink()
+		link.AppendChild(link, ast.NewString(n.Label(source)))
+		n.Parent().ReplaceChild(n.Parent(), n, link)
+	}
+}
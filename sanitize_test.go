@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeURLs(t *testing.T) {
+	tests := []struct {
+		name, md string
+		// substrings the html must and must not contain
+		want, notWant []string
+	}{
+		{
+			name:    "markdown link",
+			md:      "[x](javascript:alert(1))",
+			notWant: []string{"javascript:"},
+		},
+		{
+			name:    "markdown image",
+			md:      "![y](javascript:alert(2))",
+			notWant: []string{"javascript:"},
+		},
+		{
+			name:    "obfuscated scheme",
+			md:      "[x](&#106;avascript:alert(1)) [y](JaVaScRiPt:alert(2)) <a href=\" JaVaScRiPt:alert(3)\">a</a>",
+			notWant: []string{"script:"},
+		},
+		{
+			name:    "autolink",
+			md:      "<javascript:alert(1)> <JAVASCRIPT:x>",
+			notWant: []string{`href="javascript:`},
+		},
+		{
+			name:    "raw html",
+			md:      `<a href="javascript:alert(1)" onclick="x">a</a> <img src="vbscript:x">`,
+			want:    []string{"<a>a</a>"},
+			notWant: []string{"script:", "onclick"},
+		},
+		{
+			name:    "image attribute list",
+			md:      `![x](a.png){width=10 style="x" srcset="javascript:alert(1) 1x" usemap="#m" onerror="alert(2)" .c}`,
+			want:    []string{`width="10"`, `class="c"`},
+			notWant: []string{"style", "srcset", "usemap", "onerror", "script:"},
+		},
+		{
+			name: "safe URLs",
+			md:   "[x](https://example.com) ![y](img/a.png) <a href=\"#top\">top</a> <https://b.example>",
+			want: []string{`href="https://example.com"`, `src="img/a.png"`, `href="#top"`, `href="https://b.example"`},
+		},
+	}
+	md, err := markdownFor(markdownOptions{html: htmlSanitize, ext: "+figure"}, nil, &config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := md.Convert([]byte(tt.md), &out); err != nil {
+				t.Fatal(err)
+			}
+			got := out.String()
+			for _, s := range tt.want {
+				if !strings.Contains(got, s) {
+					t.Errorf("html lacks %q:\n%s", s, got)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(strings.ToLower(got), strings.ToLower(s)) {
+					t.Errorf("html contains %q:\n%s", s, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSanitizeAllowlist(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`<b onclick="x">bold</b>`, `<b>bold</b>`},
+		{`<script>alert(1)</script>after`, `after`},
+		{`<!-- comment -->text`, `text`},
+		{`<custom>x</custom>`, `x`},
+		{`<img src="a.png" alt="a" style="x">`, `<img src="a.png" alt="a">`},
+		{`1 < 2`, `1 &lt; 2`},
+	}
+	p := newHTMLPolicy(nil, nil)
+	for _, tt := range tests {
+		if got := string(p.sanitize([]byte(tt.in), false)); got != tt.want {
+			t.Errorf("sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeXHTML(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`a<br>b`, `a<br />b`},
+		{`<img src="a.png" alt="a">`, `<img src="a.png" alt="a" />`},
+		{`<hr/></br>`, `<hr />`},
+		{`<details open><summary>s</summary></details>`, `<details open="open"><summary>s</summary></details>`},
+	}
+	p := newHTMLPolicy(nil, nil)
+	for _, tt := range tests {
+		if got := string(p.sanitize([]byte(tt.in), true)); got != tt.want {
+			t.Errorf("sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
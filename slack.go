@@ -0,0 +1,374 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// slackMessageLimit is the length in characters Slack recommends messages stay under; longer ones
+// get truncated
+const slackMessageLimit = 4000
+
+// slackEscaper escapes the characters Slack takes as control characters in mrkdwn
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackMessage is the payload of a message posted to a Slack incoming webhook
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// renderSlack converts the doc to Slack mrkdwn, split into messages under Slack's length limit. Each
+// message is written as a JSON webhook payload on its own line, ready to post.
+func renderSlack(w io.Writer, md goldmark.Markdown, doc *document) error {
+	root := md.Parser().Parse(text.NewReader(doc.source))
+	sw := &slackWriter{source: doc.source, footnotes: map[int]*east.Footnote{}, noteNums: map[int]int{}}
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if fn, ok := n.(*east.Footnote); ok && entering {
+			sw.footnotes[fn.Index] = fn
+		}
+		return ast.WalkContinue, nil
+	})
+	var blocks []string
+	for c := root.FirstChild(); c != nil; c = c.NextSibling() {
+		if b := sw.block(c, 0); b != "" {
+			blocks = append(blocks, b)
+		}
+	}
+	for i, note := range sw.notes {
+		blocks = append(blocks, "["+strconv.Itoa(i+1)+"] "+note)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, msg := range packSlackMessages(blocks, slackMessageLimit) {
+		if err := enc.Encode(slackMessage{Text: msg}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packSlackMessages packs blocks into as few messages under limit as possible; blocks over the limit
+// get split by lines, code blocks into several code blocks
+func packSlackMessages(blocks []string, limit int) []string {
+	var msgs []string
+	var cur string
+	add := func(b string) {
+		switch {
+		case cur == "":
+			cur = b
+		case utf8.RuneCountInString(cur)+2+utf8.RuneCountInString(b) <= limit:
+			cur += "\n\n" + b
+		default:
+			msgs = append(msgs, cur)
+			cur = b
+		}
+	}
+	for _, b := range blocks {
+		if utf8.RuneCountInString(b) <= limit {
+			add(b)
+			continue
+		}
+		fenceOpen, fenceClose := "", ""
+		if strings.HasPrefix(b, "```\n") && strings.HasSuffix(b, "\n```") {
+			fenceOpen, fenceClose = "```\n", "\n```"
+			b = b[len(fenceOpen) : len(b)-len(fenceClose)]
+		}
+		room := limit - len(fenceOpen) - len(fenceClose)
+		var chunk []string
+		size := 0
+		flush := func() {
+			if chunk != nil {
+				add(fenceOpen + strings.Join(chunk, "\n") + fenceClose)
+				chunk, size = nil, 0
+			}
+		}
+		for _, line := range strings.Split(b, "\n") {
+			// lines over the limit by themselves get cut
+			for utf8.RuneCountInString(line) > room {
+				flush()
+				cut := len(string([]rune(line)[:room]))
+				if sp := strings.LastIndexByte(line[:cut], ' '); sp > 0 {
+					cut = sp
+				}
+				add(fenceOpen + line[:cut] + fenceClose)
+				line = strings.TrimLeft(line[cut:], " ")
+			}
+			n := utf8.RuneCountInString(line)
+			if chunk != nil && size+1+n > room {
+				flush()
+			}
+			if chunk != nil {
+				size++
+			}
+			chunk = append(chunk, line)
+			size += n
+		}
+		flush()
+	}
+	if cur != "" {
+		msgs = append(msgs, cur)
+	}
+	return msgs
+}
+
+// slackWriter converts a goldmark AST to Slack mrkdwn
+type slackWriter struct {
+	source []byte
+	// footnote definitions by index, footnotes referenced so far in order, converted, and their
+	// numbers by index
+	footnotes map[int]*east.Footnote
+	notes     []string
+	noteNums  map[int]int
+}
+
+// blocks converts the children of parent, w/ blank lines between them unless tight
+func (w *slackWriter) blocks(parent ast.Node, depth int, tight bool) string {
+	var parts []string
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		if b := w.block(c, depth); b != "" {
+			parts = append(parts, b)
+		}
+	}
+	if tight {
+		return strings.Join(parts, "\n")
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// block converts n; depth is the nesting of lists n is in
+func (w *slackWriter) block(n ast.Node, depth int) string {
+	switch n := n.(type) {
+	case *ast.Heading:
+		// mrkdwn has no headings
+		return "*" + slackEscaper.Replace(strings.ReplaceAll(plainText(n, w.source), "\n", " ")) + "*"
+	case *ast.Paragraph, *ast.TextBlock:
+		return w.inlines(n)
+	case *ast.ThematicBreak:
+		return "───"
+	case *ast.CodeBlock, *ast.FencedCodeBlock:
+		var b strings.Builder
+		for i := 0; i < n.Lines().Len(); i++ {
+			seg := n.Lines().At(i)
+			b.Write(seg.Value(w.source))
+		}
+		return slackCode(b.String())
+	case *mathBlock:
+		return slackCode(mathText(n, w.source))
+	case *ast.Blockquote:
+		return slackQuote(w.blocks(n, 0, false))
+	case *ast.List:
+		return w.list(n, depth)
+	case *east.DefinitionList:
+		var parts []string
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			if _, ok := c.(*east.DefinitionTerm); ok {
+				parts = append(parts, "*"+slackEscaper.Replace(plainText(c, w.source))+"*")
+				continue
+			}
+			parts = append(parts, strings.Join(prefixTermLines(strings.Split(w.blocks(c, 0, true), "\n"), "    ", "    "), "\n"))
+		}
+		return strings.Join(parts, "\n")
+	case *east.Table:
+		var rows [][]string
+		for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+			var cells []string
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, strings.ReplaceAll(plainText(cell, w.source), "\n", " "))
+			}
+			rows = append(rows, cells)
+		}
+		return w.table(rows, n.Alignments, true, "")
+	case *csvTable:
+		var more string
+		if n.omitted == 1 {
+			more = "... 1 more row"
+		} else if n.omitted > 0 {
+			more = fmt.Sprintf("... %d more rows", n.omitted)
+		}
+		return w.table(n.rows, nil, n.header, more)
+	case *containerBlock:
+		title := n.title
+		if title == "" {
+			title = strings.ToUpper(n.name[:1]) + n.name[1:]
+		}
+		return slackQuote("*" + slackEscaper.Replace(title) + "*\n" + w.blocks(n, 0, false))
+	case *figureBlock:
+		s := w.inlines(n)
+		if len(n.caption) > 0 {
+			s += "\n_" + slackEscaper.Replace(string(n.caption)) + "_"
+		}
+		return s
+	case *ast.HTMLBlock, *east.FootnoteList:
+		// raw html is not shown, as by default in html output; footnotes go to the end
+		return ""
+	}
+	return w.blocks(n, depth, false)
+}
+
+func (w *slackWriter) list(n *ast.List, depth int) string {
+	bullets := []string{"•", "◦", "▪"}
+	indent := strings.Repeat("    ", depth)
+	num := n.Start
+	var items []string
+	for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+		marker := bullets[depth%len(bullets)] + " "
+		if n.IsOrdered() {
+			marker = strconv.Itoa(num) + ". "
+			num++
+		}
+		var parts []string
+		for c := item.FirstChild(); c != nil; c = c.NextSibling() {
+			if l, ok := c.(*ast.List); ok {
+				// nested lists indent themselves
+				parts = append(parts, w.list(l, depth+1))
+				continue
+			}
+			first := indent + "    "
+			if len(parts) == 0 {
+				first = indent + marker
+			}
+			b := prefixTermLines(strings.Split(w.block(c, depth+1), "\n"), first, indent+"    ")
+			parts = append(parts, strings.Join(b, "\n"))
+		}
+		if len(parts) == 0 {
+			parts = []string{indent + marker}
+		}
+		sep := "\n"
+		if !n.IsTight {
+			sep = "\n\n"
+		}
+		items = append(items, strings.Join(parts, sep))
+	}
+	if n.IsTight {
+		return strings.Join(items, "\n")
+	}
+	return strings.Join(items, "\n\n")
+}
+
+// table lays out a table as text in a code block, as mrkdwn has no tables; more is a line to go
+// below it, if any
+func (w *slackWriter) table(rows [][]string, aligns []east.Alignment, header bool, more string) string {
+	plain := func(s string) string { return s }
+	lines := boxTable(rows, aligns, header, 1<<16, textBox, plain, plain)
+	if more != "" {
+		lines = append(lines, more)
+	}
+	return slackCode(strings.Join(lines, "\n"))
+}
+
+// slackCode returns text as a code block
+func slackCode(s string) string {
+	return "```\n" + slackEscaper.Replace(strings.TrimRight(s, "\n")) + "\n```"
+}
+
+// slackQuote quotes every line of s
+func slackQuote(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = "> " + l
+	}
+	return strings.Join(lines, "\n")
+}
+
+// inlines returns the mrkdwn of the inline children of parent
+func (w *slackWriter) inlines(parent ast.Node) string {
+	var b strings.Builder
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		b.WriteString(w.inline(c))
+	}
+	return b.String()
+}
+
+func (w *slackWriter) inline(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Text:
+		s := slackEscaper.Replace(leafText(n, w.source))
+		if n.HardLineBreak() {
+			s += "\n"
+		} else if n.SoftLineBreak() {
+			// Slack keeps line breaks, so soft ones go
+			s += " "
+		}
+		return s
+	case *ast.String:
+		return slackEscaper.Replace(leafText(n, w.source))
+	case *ast.CodeSpan:
+		return "`" + slackEscaper.Replace(plainText(n, w.source)) + "`"
+	case *mathInline:
+		return "`" + slackEscaper.Replace(mathText(n, w.source)) + "`"
+	case *ast.Emphasis:
+		if n.Level >= 2 {
+			return "*" + w.inlines(n) + "*"
+		}
+		return "_" + w.inlines(n) + "_"
+	case *east.Strikethrough:
+		return "~" + w.inlines(n) + "~"
+	case *inlineTag:
+		switch n.syntax.tag {
+		case "kbd":
+			return "`" + slackEscaper.Replace(plainText(n, w.source)) + "`"
+		case "mark":
+			return "*" + w.inlines(n) + "*"
+		}
+		return w.inlines(n)
+	case *ast.Link:
+		return w.link(string(n.Destination), w.inlines(n))
+	case *ast.AutoLink:
+		url := string(n.URL(w.source))
+		if n.AutoLinkType == ast.AutoLinkEmail && !strings.HasPrefix(strings.ToLower(url), "mailto:") {
+			url = "mailto:" + url
+		}
+		return w.link(url, slackEscaper.Replace(string(n.Label(w.source))))
+	case *wikiLink:
+		return w.link(n.href, w.inlines(n))
+	case *ast.Image:
+		alt := slackEscaper.Replace(plainText(n, w.source))
+		if alt == "" {
+			alt = "image"
+		}
+		return w.link(string(n.Destination), alt)
+	case *east.TaskCheckBox:
+		if n.IsChecked {
+			return "☑ "
+		}
+		return "☐ "
+	case *east.FootnoteLink:
+		fn, ok := w.footnotes[n.Index]
+		if !ok {
+			return ""
+		}
+		num, ok := w.noteNums[n.Index]
+		if !ok {
+			w.notes = append(w.notes, "")
+			num = len(w.notes)
+			w.noteNums[n.Index] = num
+			w.notes[num-1] = w.blocks(fn, 0, true)
+		}
+		return "[" + strconv.Itoa(num) + "]"
+	case *ast.RawHTML, *east.FootnoteBacklink, *imageAttrs:
+		return ""
+	}
+	return w.inlines(n)
+}
+
+// link returns a mrkdwn link; Slack can't link within the doc, nor to relative URLs, so those are
+// just their label
+func (w *slackWriter) link(dest, label string) string {
+	if dest == "" || strings.HasPrefix(dest, "#") || !strings.Contains(dest, ":") {
+		return label
+	}
+	dest = strings.NewReplacer("<", "%3C", ">", "%3E", "|", "%7C").Replace(dest)
+	if label == "" || label == slackEscaper.Replace(dest) {
+		return "<" + dest + ">"
+	}
+	return "<" + dest + "|" + strings.ReplaceAll(label, "\n", " ") + ">"
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// speakerNotesRe matches speaker notes comments like `<!-- notes: Mention the Q3 numbers -->`
+var speakerNotesRe = regexp.MustCompile(`(?s)^<!--\s*notes:\s*(.*?)\s*-->$`)
+
+// slide is a rendered slide of a deck
+type slide struct {
+	Body, Notes template.HTML
+}
+
+// renderSlides renders the doc as a self-contained html slide deck. Slides are split at top level
+// thematic breaks or, w/o any, at H1 and H2 headings.
+func renderSlides(w io.Writer, md goldmark.Markdown, doc *document) error {
+	root := md.Parser().Parse(text.NewReader(doc.source))
+	title := doc.title(root)
+	if title == "" {
+		title = "Slides"
+	}
+	var slides []slide
+	for _, nodes := range splitSlides(root) {
+		s, err := renderSlide(md, doc.source, nodes)
+		if err != nil {
+			return err
+		}
+		slides = append(slides, s)
+	}
+	return slideDeckTemplate.Execute(w, struct {
+		Title  string
+		CSS    template.CSS
+		JS     template.JS
+		Slides []slide
+	}{
+		Title:  title,
+		CSS:    template.CSS(markDownStyleGithubCSS + codeBlockCSS + slideDeckCSS),
+		JS:     template.JS(slideDeckJS),
+		Slides: slides,
+	})
+}
+
+// splitSlides groups top level nodes of root into slides; empty slides are dropped
+func splitSlides(root ast.Node) [][]ast.Node {
+	byBreaks := false
+	for c := root.FirstChild(); c != nil; c = c.NextSibling() {
+		if c.Kind() == ast.KindThematicBreak {
+			byBreaks = true
+			break
+		}
+	}
+	var slides [][]ast.Node
+	var cur []ast.Node
+	for c := root.FirstChild(); c != nil; c = c.NextSibling() {
+		h, isHeading := c.(*ast.Heading)
+		switch {
+		case byBreaks && c.Kind() == ast.KindThematicBreak:
+			slides = append(slides, cur)
+			cur = nil
+			continue
+		case !byBreaks && isHeading && h.Level <= 2:
+			slides = append(slides, cur)
+			cur = nil
+		}
+		cur = append(cur, c)
+	}
+	slides = append(slides, cur)
+	var nonEmpty [][]ast.Node
+	for _, s := range slides {
+		if len(s) > 0 {
+			nonEmpty = append(nonEmpty, s)
+		}
+	}
+	return nonEmpty
+}
+
+// renderSlide renders given nodes as a slide; speaker notes among them go to its notes
+func renderSlide(md goldmark.Markdown, source []byte, nodes []ast.Node) (slide, error) {
+	var s slide
+	var notes bytes.Buffer
+	doc := ast.NewDocument()
+	for _, n := range nodes {
+		if n.Kind() == ast.KindHTMLBlock {
+			if start, stop, ok := blockRange(n, source); ok {
+				if m := speakerNotesRe.FindSubmatch(bytes.TrimSpace(source[start:stop])); m != nil {
+					if err := md.Convert(m[1], &notes); err != nil {
+						return s, fmt.Errorf("error rendering speaker notes: %w", err)
+					}
+					continue
+				}
+			}
+		}
+		doc.AppendChild(doc, n)
+	}
+	var body bytes.Buffer
+	if err := md.Renderer().Render(&body, source, doc); err != nil {
+		return s, fmt.Errorf("error rendering slide: %w", err)
+	}
+	s.Body = template.HTML(body.String())
+	s.Notes = template.HTML(notes.String())
+	return s, nil
+}
+
+var slideDeckTemplate = template.Must(template.New("slides").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+{{.CSS}}
+</style>
+</head>
+<body>
+{{range .Slides}}<section class="slide markdown-body">
+{{.Body}}{{if .Notes}}<aside class="notes">
+{{.Notes}}</aside>
+{{end}}</section>
+{{end}}<div class="deck-progress"><div class="deck-progress-bar"></div></div>
+<div class="deck-counter"></div>
+<script>
+{{.JS}}
+</script>
+</body>
+</html>
+`))
+
+// slideDeckCSS lays out slides on top of the GitHub style: 1 slide per screen, or per page in print
+// where speaker notes show below each slide as handouts
+const slideDeckCSS = `
+html, body {
+  height: 100%;
+  margin: 0;
+  background-color: #ffffff;
+}
+
+.slide.markdown-body {
+  display: none;
+  box-sizing: border-box;
+  height: 100vh;
+  padding: 6vh 8vw;
+  overflow: auto;
+  font-size: 24px;
+}
+
+.slide.markdown-body.active {
+  display: block;
+}
+
+.slide .notes {
+  display: none;
+  padding-top: 8px;
+  margin-top: 32px;
+  font-size: 60%;
+  color: #59636e;
+  border-top: 1px dashed #d1d9e0;
+}
+
+body.show-notes .slide .notes {
+  display: block;
+}
+
+.deck-progress {
+  position: fixed;
+  bottom: 0;
+  left: 0;
+  width: 100%;
+  height: 4px;
+  background-color: #d1d9e0;
+}
+
+.deck-progress-bar {
+  height: 100%;
+  background-color: #0969da;
+  transition: width 0.2s;
+}
+
+.deck-counter {
+  position: fixed;
+  right: 16px;
+  bottom: 12px;
+  font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", "Noto Sans", Helvetica, Arial, sans-serif;
+  font-size: 14px;
+  color: #59636e;
+}
+
+@media print {
+  .slide.markdown-body {
+    display: block;
+    height: auto;
+    overflow: visible;
+    font-size: 16px;
+    break-after: page;
+  }
+
+  .slide .notes {
+    display: block;
+  }
+
+  .deck-progress,
+  .deck-counter {
+    display: none;
+  }
+}
+`
+
+// slideDeckJS navigates slides w/ keys (arrows, space, page up/down, home, end) and clicks, keeps the
+// current slide in the URL fragment and toggles speaker notes on `n`
+const slideDeckJS = `(function () {
+  var slides = document.querySelectorAll('.slide');
+  var bar = document.querySelector('.deck-progress-bar');
+  var counter = document.querySelector('.deck-counter');
+  var current = 0;
+  function show(i) {
+    if (slides.length === 0) {
+      return;
+    }
+    current = Math.max(0, Math.min(slides.length - 1, i));
+    for (var j = 0; j < slides.length; j++) {
+      slides[j].classList.toggle('active', j === current);
+    }
+    bar.style.width = ((current + 1) / slides.length * 100) + '%';
+    counter.textContent = (current + 1) + ' / ' + slides.length;
+    history.replaceState(null, '', '#' + (current + 1));
+  }
+  document.addEventListener('keydown', function (e) {
+    if (e.altKey || e.ctrlKey || e.metaKey) {
+      return;
+    }
+    switch (e.key) {
+    case 'ArrowRight': case 'ArrowDown': case 'PageDown': case ' ': case 'Enter':
+      show(current + 1);
+      break;
+    case 'ArrowLeft': case 'ArrowUp': case 'PageUp': case 'Backspace':
+      show(current - 1);
+      break;
+    case 'Home':
+      show(0);
+      break;
+    case 'End':
+      show(slides.length - 1);
+      break;
+    case 'n':
+      document.body.classList.toggle('show-notes');
+      break;
+    default:
+      return;
+    }
+    e.preventDefault();
+  });
+  document.addEventListener('click', function (e) {
+    if (e.target.closest('a, summary, input') || window.getSelection().toString() !== '') {
+      return;
+    }
+    show(current + (e.clientX > window.innerWidth / 2 ? 1 : -1));
+  });
+  // fragments other than slide numbers are links within slides e.g. to footnotes
+  function showHash() {
+    var n = parseInt(location.hash.slice(1), 10);
+    if (!isNaN(n)) {
+      show(n - 1);
+    }
+  }
+  window.addEventListener('hashchange', showHash);
+  show(0);
+  showHash();
+})();`
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// snippet attributes of fenced code blocks, e.g.
+//
+//	```go file=../cmd/server/main.go lines=10-42
+//	```
+//	```file=../cmd/server/main.go region=handler
+//	```
+//
+// fill the block w/ the referenced part of the file at render time
+var snippetAttrs = map[string]bool{"file": true, "lines": true, "region": true}
+
+// region markers are comments of their own line like `// region handler`, `# region handler`,
+// `#region handler` or `<!-- #region handler -->` and end w/ a matching `endregion` comment; regions
+// may nest
+var (
+	regionStartRe = regexp.MustCompile(`^\s*(?://+|#|--|;+|/\*|<!--)\s*#?region[:\s]+([\w.-]+)`)
+	regionEndRe   = regexp.MustCompile(`^\s*(?://+|#|--|;+|/\*|<!--)\s*#?endregion\b`)
+)
+
+// languages of well known file extensions; others map to the extension itself
+var extLanguages = map[string]string{
+	".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp", ".hpp": "cpp", ".cs": "csharp", ".css": "css",
+	".go": "go", ".html": "html", ".java": "java", ".js": "javascript", ".json": "json", ".kt": "kotlin",
+	".md": "markdown", ".mjs": "javascript", ".php": "php", ".proto": "protobuf", ".py": "python",
+	".rb": "ruby", ".rs": "rust", ".sh": "bash", ".sql": "sql", ".swift": "swift", ".toml": "toml",
+	".ts": "typescript", ".tsx": "tsx", ".xml": "xml", ".yaml": "yaml", ".yml": "yaml",
+}
+
+// expandSnippets fills fenced code blocks carrying snippet attributes in src, which is read from
+// path ("-" for stdin); referenced files resolve relative to the doc. The edits made are returned
+// as well, for mapping offsets back to src.
+func expandSnippets(md goldmark.Markdown, path string, src []byte) ([]byte, []sourceEdit, error) {
+	edits, err := snippetEdits(md, inputDir(path), src)
+	if err != nil {
+		return nil, nil, err
+	}
+	return applyEdits(src, edits), edits, nil
+}
+
+func expandSnippetsIn(md goldmark.Markdown, dir string, src []byte) ([]byte, error) {
+	edits, err := snippetEdits(md, dir, src)
+	if err != nil {
+		return nil, err
+	}
+	return applyEdits(src, edits), nil
+}
+
+func snippetEdits(md goldmark.Markdown, dir string, src []byte) ([]sourceEdit, error) {
+	doc := md.Parser().Parse(text.NewReader(src))
+	var edits []sourceEdit
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		fcb, ok := n.(*ast.FencedCodeBlock)
+		if !entering || !ok || fcb.Info == nil {
+			return ast.WalkContinue, nil
+		}
+		info := string(fcb.Info.Segment.Value(src))
+		fields := splitQuoted(info)
+		var kept []string
+		attrs := map[string]string{}
+		for _, f := range fields {
+			if k, v, ok := strings.Cut(f, "="); ok && snippetAttrs[k] {
+				attrs[k] = unquote(v)
+				continue
+			}
+			kept = append(kept, f)
+		}
+		if attrs["file"] == "" {
+			if len(attrs) > 0 {
+				return ast.WalkStop, fmt.Errorf("code block %q: snippet attributes require file=", info)
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		snippet, err := readSnippet(filepath.Join(dir, attrs["file"]), attrs["lines"], attrs["region"])
+		if err != nil {
+			return ast.WalkStop, fmt.Errorf("code block %q: %w", info, err)
+		}
+		// w/o a leading language the one of the file is assumed
+		if len(kept) == 0 || strings.Contains(kept[0], "=") || strings.HasPrefix(kept[0], "{") {
+			ext := strings.ToLower(filepath.Ext(attrs["file"]))
+			lang, ok := extLanguages[ext]
+			if !ok {
+				lang = strings.TrimPrefix(ext, ".")
+			}
+			if lang != "" {
+				kept = append([]string{lang}, kept...)
+			}
+		}
+		edits = append(edits, sourceEdit{
+			start: fcb.Info.Segment.Start,
+			stop:  fcb.Info.Segment.Stop,
+			text:  []byte(strings.Join(kept, " ")),
+		})
+
+		// replace whatever the block holds w/ the snippet, keeping the prefix of containers it sits in
+		lineStart := bytes.LastIndexByte(src[:fcb.Info.Segment.Start], '\n') + 1
+		fenceAt := lineStart
+		for fenceAt < len(src) && src[fenceAt] != '`' && src[fenceAt] != '~' {
+			fenceAt++
+		}
+		prefix := strings.Map(func(r rune) rune {
+			if r == '>' || r == '\t' {
+				return r
+			}
+			return ' '
+		}, string(src[lineStart:fenceAt]))
+		var body strings.Builder
+		contentStart := len(src)
+		if nl := bytes.IndexByte(src[fcb.Info.Segment.Stop:], '\n'); nl >= 0 {
+			contentStart = fcb.Info.Segment.Stop + nl + 1
+		} else {
+			// unclosed block w/o even a newline at EOF
+			body.WriteString("\n")
+		}
+		contentStop := contentStart
+		if lines := fcb.Lines(); lines.Len() > 0 {
+			contentStop = lines.At(lines.Len() - 1).Stop
+			// lines hold content w/o container prefixes; start from the beginning of the first line
+			contentStart = bytes.LastIndexByte(src[:lines.At(0).Start], '\n') + 1
+		}
+		for _, line := range strings.SplitAfter(snippet, "\n") {
+			if line != "" {
+				body.WriteString(prefix + line)
+			}
+		}
+		edits = append(edits, sourceEdit{start: contentStart, stop: contentStop, text: []byte(body.String())})
+		return ast.WalkSkipChildren, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+// readSnippet returns lines of given file, narrowed down to a line range like `10-42` and/or a region;
+// the result is dedented and ends w/ a newline
+func readSnippet(path, lines, region string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading snippet file: %w", err)
+	}
+	all := strings.Split(strings.TrimRight(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"), "\n")
+	selected := all
+	if region != "" {
+		start, end, depth := -1, -1, 0
+		for i, line := range all {
+			if start < 0 {
+				if m := regionStartRe.FindStringSubmatch(line); m != nil && m[1] == region {
+					start = i + 1
+				}
+			} else if regionStartRe.MatchString(line) {
+				depth++
+			} else if regionEndRe.MatchString(line) {
+				if depth == 0 {
+					end = i
+					break
+				}
+				depth--
+			}
+		}
+		if start < 0 {
+			return "", fmt.Errorf("region %q not found in %s", region, path)
+		}
+		if end < 0 {
+			return "", fmt.Errorf("region %q in %s has no endregion marker", region, path)
+		}
+		// markers of regions nested in the one picked are not part of the code shown
+		selected = nil
+		for _, line := range all[start:end] {
+			if !regionStartRe.MatchString(line) && !regionEndRe.MatchString(line) {
+				selected = append(selected, line)
+			}
+		}
+	}
+	if lines != "" {
+		from, to, err := parseLineRange(lines, len(selected))
+		if err != nil {
+			return "", fmt.Errorf("invalid lines=%s for %s: %w", lines, path, err)
+		}
+		selected = selected[from-1 : to]
+	}
+	return dedent(selected), nil
+}
+
+// parseLineRange parses 1-based inclusive ranges like `10-42`, `10-` or `7` within n lines
+func parseLineRange(s string, n int) (from, to int, err error) {
+	a, b, isRange := strings.Cut(s, "-")
+	if from, err = strconv.Atoi(a); err != nil {
+		return 0, 0, err
+	}
+	to = from
+	if isRange {
+		to = n
+		if b != "" {
+			if to, err = strconv.Atoi(b); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	if from < 1 || to < from || to > n {
+		return 0, 0, fmt.Errorf("range out of bounds (1-%d)", n)
+	}
+	return from, to, nil
+}
+
+// dedent strips the indentation common to all non-blank lines
+func dedent(lines []string) string {
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			line = line[indent:]
+		} else if strings.TrimSpace(line) == "" {
+			line = ""
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadSnippet(t *testing.T) {
+	src := `package db
+
+// region query
+func query() string {
+	q := "select region from sales"
+	return q // endregion of sales is unused
+}
+// endregion
+
+#region csharp
+	x := 1
+	// region inner
+	y := 2
+	// endregion
+	z := 3
+#endregion
+
+<!-- #region html -->
+<p>endregion</p>
+<!-- #endregion -->
+`
+	path := filepath.Join(t.TempDir(), "db.go")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, lines, region string
+		want                string
+		wantErr             bool
+	}{
+		{
+			name:   "region w/ marker words in code",
+			region: "query",
+			want:   "func query() string {\n\tq := \"select region from sales\"\n\treturn q // endregion of sales is unused\n}\n",
+		},
+		{
+			name:   "nested regions",
+			region: "csharp",
+			want:   "x := 1\ny := 2\nz := 3\n",
+		},
+		{
+			name:   "inner region",
+			region: "inner",
+			want:   "y := 2\n",
+		},
+		{
+			name:   "html comments",
+			region: "html",
+			want:   "<p>endregion</p>\n",
+		},
+		{
+			name:   "lines within region",
+			region: "csharp",
+			lines:  "2-3",
+			want:   "y := 2\nz := 3\n",
+		},
+		{
+			name:  "lines keep markers",
+			lines: "3-4",
+			want:  "// region query\nfunc query() string {\n",
+		},
+		{
+			name:    "missing region",
+			region:  "sales",
+			wantErr: true,
+		},
+		{
+			name:    "lines out of range",
+			lines:   "20-99",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readSnippet(path, tt.lines, tt.region)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
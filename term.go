@@ -0,0 +1,782 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// width of terminal output when neither $COLUMNS nor the terminal tell
+const defaultTermWidth = 80
+
+// termStyle is a pair of ANSI SGR sequences switching a style on and off; off sequences reset only
+// what the style sets, so that styles nest
+type termStyle struct {
+	on, off string
+}
+
+var (
+	termBold      = termStyle{"\x1b[1m", "\x1b[22m"}
+	termDim       = termStyle{"\x1b[2m", "\x1b[22m"}
+	termItalic    = termStyle{"\x1b[3m", "\x1b[23m"}
+	termStrike    = termStyle{"\x1b[9m", "\x1b[29m"}
+	termReverse   = termStyle{"\x1b[7m", "\x1b[27m"}
+	termMark      = termStyle{"\x1b[30;43m", "\x1b[39;49m"}
+	termCode      = termStyle{"\x1b[36m", "\x1b[39m"}
+	termLink      = termStyle{"\x1b[4;34m", "\x1b[24;39m"}
+	termHeading   = termStyle{"\x1b[1;35m", "\x1b[22;39m"}
+	termHeadingUL = termStyle{"\x1b[1;4;35m", "\x1b[22;24;39m"}
+	termComment   = termStyle{"\x1b[90m", "\x1b[39m"}
+	termString    = termStyle{"\x1b[32m", "\x1b[39m"}
+	termNumber    = termStyle{"\x1b[33m", "\x1b[39m"}
+	termKeyword   = termStyle{"\x1b[35m", "\x1b[39m"}
+)
+
+// colors of alert containers
+var termAlertStyles = map[string]termStyle{
+	"note":      {"\x1b[34m", "\x1b[39m"},
+	"tip":       {"\x1b[32m", "\x1b[39m"},
+	"important": {"\x1b[35m", "\x1b[39m"},
+	"warning":   {"\x1b[33m", "\x1b[39m"},
+	"caution":   {"\x1b[31m", "\x1b[39m"},
+}
+
+// termEscapeRe matches the escape sequences of terminal output: SGR styles and OSC 8 hyperlinks
+var termEscapeRe = regexp.MustCompile(`\x1b\[[0-9;]*m|\x1b\]8;;[^\x1b]*\x1b\\`)
+
+// termControlRe matches C0 and C1 control characters but tab and newline, which docs could use to
+// send escape sequences of their own to the terminal
+var termControlRe = regexp.MustCompile(`[\x00-\x08\x0b-\x1f\x7f\x{80}-\x{9f}]`)
+
+// termClean strips control characters from content of the doc
+func termClean(s string) string {
+	return termControlRe.ReplaceAllString(s, "")
+}
+
+// termOffRe matches the SGR sequences switching styles off, as termStyle.off
+var termOffRe = regexp.MustCompile(`^\x1b\[(?:(?:22|23|24|27|29|39|49);?)+m$`)
+
+// isTerminal tells whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// termWidth returns the width to lay out terminal output in: $COLUMNS, else the width of the terminal
+// stdout is
+func termWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	if n, ok := terminalWidth(os.Stdout); ok {
+		return n
+	}
+	return defaultTermWidth
+}
+
+// pager is output piped through a pager program
+type pager struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+// startPager runs the pager command line, e.g. $PAGER, writing to stdout
+func startPager(cmdline string) (*pager, error) {
+	cmd := exec.Command("sh", "-c", cmdline)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	// as git does: quit if 1 screen is enough, pass colors through and keep output on screen
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting pager %q: %w", cmdline, err)
+	}
+	return &pager{WriteCloser: in, cmd: cmd}, nil
+}
+
+// Close ends the output and waits for the pager to quit
+func (p *pager) Close() error {
+	if err := p.WriteCloser.Close(); err != nil {
+		return err
+	}
+	return p.cmd.Wait()
+}
+
+// renderTerm renders the doc for terminals: styled w/ ANSI escape sequences, w/ OSC 8 hyperlinks and
+// laid out in the width of the terminal. $NO_COLOR turns styles and hyperlinks off.
+func renderTerm(w io.Writer, md goldmark.Markdown, doc *document) error {
+	root := md.Parser().Parse(text.NewReader(doc.source))
+	tw := &termWriter{
+		source:    doc.source,
+		color:     os.Getenv("NO_COLOR") == "",
+		hardWraps: slices.Contains(doc.extensions, "hardwraps"),
+		footnotes: map[int]*east.Footnote{},
+		noteNums:  map[int]int{},
+		width:     termWidth(),
+	}
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if fn, ok := n.(*east.Footnote); ok && entering {
+			tw.footnotes[fn.Index] = fn
+		}
+		return ast.WalkContinue, nil
+	})
+	lines := tw.blocks(root, tw.width, false)
+	if len(tw.notes) > 0 {
+		lines = append(lines, "", tw.style(termDim, strings.Repeat("─", min(tw.width, 20))))
+		for i, note := range tw.notes {
+			marker := "[" + strconv.Itoa(i+1) + "] "
+			lines = append(lines, prefixTermLines(note, marker, strings.Repeat(" ", len(marker)))...)
+		}
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// termWriter lays out a goldmark AST as lines of terminal output
+type termWriter struct {
+	source []byte
+	// whether to write escape sequences
+	color bool
+	// whether soft line breaks break lines, per the hardwraps extension
+	hardWraps bool
+	// footnote definitions by index, footnotes referenced so far in order, laid out, and their numbers
+	// by index
+	footnotes map[int]*east.Footnote
+	notes     [][]string
+	noteNums  map[int]int
+	// width of the page, for footnotes
+	width int
+}
+
+// style returns s in given style; styles apply per line so that lines stand on their own
+func (w *termWriter) style(st termStyle, s string) string {
+	if !w.color || s == "" {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = st.on + l + st.off
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hyperlink returns text linking to url, as OSC 8 hyperlinks in terminals that support them
+func (w *termWriter) hyperlink(url, text string) string {
+	if !w.color || url == "" {
+		return text
+	}
+	return "\x1b]8;;" + url + "\x1b\\" + w.style(termLink, text) + "\x1b]8;;\x1b\\"
+}
+
+// blocks lays out the children of parent in width, w/ blank lines between them unless tight
+func (w *termWriter) blocks(parent ast.Node, width int, tight bool) []string {
+	var lines []string
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		b := w.block(c, width)
+		if b == nil {
+			continue
+		}
+		if lines != nil && !tight {
+			lines = append(lines, "")
+		}
+		lines = append(lines, b...)
+	}
+	return lines
+}
+
+func (w *termWriter) block(n ast.Node, width int) []string {
+	switch n := n.(type) {
+	case *ast.Heading:
+		st := termHeading
+		if n.Level <= 2 {
+			st = termHeadingUL
+		}
+		return termWrap(w.style(st, w.inlines(n)), width)
+	case *ast.Paragraph, *ast.TextBlock:
+		return termWrap(w.inlines(n), width)
+	case *ast.ThematicBreak:
+		return []string{w.style(termDim, strings.Repeat("─", width))}
+	case *ast.CodeBlock:
+		return prefixTermLines(w.code(n, ""), "    ", "    ")
+	case *ast.FencedCodeBlock:
+		var fi fenceInfo
+		if n.Info != nil {
+			fi = parseFenceInfo(string(n.Info.Segment.Value(w.source)))
+		}
+		lines := prefixTermLines(w.code(n, fi.language), "    ", "    ")
+		if fi.title != "" {
+			lines = append([]string{"    " + w.style(termDim, termClean(fi.title))}, lines...)
+		}
+		return lines
+	case *mathBlock:
+		return prefixTermLines(strings.Split(w.style(termCode, strings.TrimRight(termClean(mathText(n, w.source)), "\n")), "\n"), "    ", "    ")
+	case *ast.Blockquote:
+		bar := w.style(termDim, "│ ")
+		return prefixTermLines(w.blocks(n, width-2, false), bar, bar)
+	case *ast.List:
+		return w.list(n, width)
+	case *east.DefinitionList:
+		var lines []string
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			if _, ok := c.(*east.DefinitionTerm); ok {
+				if lines != nil {
+					lines = append(lines, "")
+				}
+				lines = append(lines, termWrap(w.style(termBold, w.inlines(c)), width)...)
+				continue
+			}
+			lines = append(lines, prefixTermLines(w.blocks(c, width-4, true), "    ", "    ")...)
+		}
+		return lines
+	case *east.Table:
+		var rows [][]string
+		for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+			var cells []string
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, strings.ReplaceAll(w.inlines(cell), "\n", " "))
+			}
+			rows = append(rows, cells)
+		}
+		return w.table(rows, n.Alignments, true, width)
+	case *csvTable:
+		rows := make([][]string, len(n.rows))
+		for i, row := range n.rows {
+			for _, cell := range row {
+				rows[i] = append(rows[i], termClean(cell))
+			}
+		}
+		lines := w.table(rows, nil, n.header, width)
+		if n.omitted > 0 {
+			more := fmt.Sprintf("… %d more rows", n.omitted)
+			if n.omitted == 1 {
+				more = "… 1 more row"
+			}
+			lines = append(lines, w.style(termDim, more))
+		}
+		return lines
+	case *containerBlock:
+		title := termClean(n.title)
+		if title == "" {
+			title = strings.ToUpper(n.name[:1]) + n.name[1:]
+		}
+		st, ok := termAlertStyles[alertContainers[n.name]]
+		if !ok {
+			st = termDim
+		}
+		bar := w.style(st, "┃ ")
+		lines := []string{bar + w.style(termBold, w.style(st, title))}
+		return append(lines, prefixTermLines(w.blocks(n, width-2, false), bar, bar)...)
+	case *figureBlock:
+		lines := termWrap(w.inlines(n), width)
+		if len(n.caption) > 0 {
+			lines = append(lines, termWrap(w.style(termItalic, termClean(string(n.caption))), width)...)
+		}
+		return lines
+	case *ast.HTMLBlock, *east.FootnoteList:
+		// raw html is not shown, as by default in html output; footnotes go to the end
+		return nil
+	}
+	return w.blocks(n, width, false)
+}
+
+func (w *termWriter) list(n *ast.List, width int) []string {
+	depth := 0
+	for p := n.Parent(); p != nil; p = p.Parent() {
+		if p.Kind() == ast.KindList {
+			depth++
+		}
+	}
+	bullets := []string{"•", "◦", "▪"}
+	num := n.Start
+	var lines []string
+	for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+		marker := bullets[depth%len(bullets)] + " "
+		if n.IsOrdered() {
+			marker = strconv.Itoa(num) + ". "
+			num++
+		}
+		body := w.blocks(item, width-termTextWidth(marker), n.IsTight)
+		if lines != nil && !n.IsTight {
+			lines = append(lines, "")
+		}
+		if len(body) == 0 {
+			body = []string{""}
+		}
+		lines = append(lines, prefixTermLines(body, marker, strings.Repeat(" ", termTextWidth(marker)))...)
+	}
+	return lines
+}
+
+// termBox is the box drawing of terminal tables
+var termBox = tableBox{
+	top:    [4]string{"┌", "─", "┬", "┐"},
+	sep:    [4]string{"├", "─", "┼", "┤"},
+	bottom: [4]string{"└", "─", "┴", "┘"},
+	bar:    "│",
+}
+
+func (w *termWriter) table(rows [][]string, aligns []east.Alignment, header bool, width int) []string {
+	dim := func(s string) string { return w.style(termDim, s) }
+	bold := func(s string) string { return w.style(termBold, s) }
+	return boxTable(rows, aligns, header, width, termBox, dim, bold)
+}
+
+// tableBox is the drawing of a table's box: left, fill, middle and right of the top border, the
+// separator below the header row and the bottom border, and the bar between cells
+type tableBox struct {
+	top, sep, bottom [4]string
+	bar              string
+}
+
+// boxTable lays out rows in a box, w/ border styling the box and head the cells of the header row;
+// cells wrap when the table would not fit in width otherwise
+func boxTable(rows [][]string, aligns []east.Alignment, header bool, width int, box tableBox, border, head func(string) string) []string {
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	if columns == 0 {
+		return nil
+	}
+	widths := make([]int, columns)
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], termTextWidth(cell))
+		}
+	}
+	// borders and padding take 3 columns per cell plus 1
+	for avail := width - 3*columns - 1; ; {
+		total, widest := 0, 0
+		for i, cw := range widths {
+			total += cw
+			if cw > widths[widest] {
+				widest = i
+			}
+		}
+		if total <= avail || widths[widest] <= 5 {
+			break
+		}
+		widths[widest]--
+	}
+	rule := func(r [4]string) string {
+		var parts []string
+		for _, cw := range widths {
+			parts = append(parts, strings.Repeat(r[1], cw+2))
+		}
+		return border(r[0] + strings.Join(parts, r[2]) + r[3])
+	}
+	bar := border(box.bar)
+	lines := []string{rule(box.top)}
+	for r, row := range rows {
+		cells := make([][]string, columns)
+		height := 1
+		for i := range cells {
+			if i < len(row) {
+				cells[i] = termWrap(row[i], widths[i])
+			}
+			height = max(height, len(cells[i]))
+		}
+		for l := 0; l < height; l++ {
+			line := bar
+			for i, cell := range cells {
+				var s string
+				if l < len(cell) {
+					s = cell[l]
+				}
+				var a east.Alignment
+				if i < len(aligns) {
+					a = aligns[i]
+				}
+				if r == 0 && header {
+					s = head(s)
+				}
+				line += " " + padTerm(s, widths[i], a) + " " + bar
+			}
+			lines = append(lines, line)
+		}
+		if r == 0 && header && len(rows) > 1 {
+			lines = append(lines, rule(box.sep))
+		}
+	}
+	return append(lines, rule(box.bottom))
+}
+
+// code returns the lines of a code block, highlighted per lang
+func (w *termWriter) code(n ast.Node, lang string) []string {
+	var b strings.Builder
+	for i := 0; i < n.Lines().Len(); i++ {
+		seg := n.Lines().At(i)
+		b.Write(seg.Value(w.source))
+	}
+	code := strings.TrimRight(termClean(b.String()), "\n")
+	// tabs would expand past the indentation
+	code = strings.ReplaceAll(code, "\t", "    ")
+	if w.color {
+		code = w.highlight(code, lang)
+	}
+	return strings.Split(code, "\n")
+}
+
+// inlines returns the styled text of the inline children of parent; soft line breaks become spaces
+// to reflow, unless hardwraps are on, and hard ones newlines
+func (w *termWriter) inlines(parent ast.Node) string {
+	var b strings.Builder
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		b.WriteString(w.inline(c))
+	}
+	return b.String()
+}
+
+func (w *termWriter) inline(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Text:
+		s := termClean(leafText(n, w.source))
+		if n.HardLineBreak() || n.SoftLineBreak() && w.hardWraps {
+			s += "\n"
+		} else if n.SoftLineBreak() {
+			s += " "
+		}
+		return s
+	case *ast.String:
+		return termClean(leafText(n, w.source))
+	case *ast.CodeSpan:
+		return w.style(termCode, termClean(plainText(n, w.source)))
+	case *mathInline:
+		return w.style(termCode, termClean(mathText(n, w.source)))
+	case *ast.Emphasis:
+		if n.Level >= 2 {
+			return w.style(termBold, w.inlines(n))
+		}
+		return w.style(termItalic, w.inlines(n))
+	case *east.Strikethrough:
+		return w.style(termStrike, w.inlines(n))
+	case *inlineTag:
+		switch n.syntax.tag {
+		case "mark":
+			return w.style(termMark, w.inlines(n))
+		case "kbd":
+			return w.style(termReverse, " "+w.inlines(n)+" ")
+		case "sub":
+			return "_" + w.inlines(n)
+		case "sup":
+			return "^" + w.inlines(n)
+		}
+		return w.inlines(n)
+	case *ast.Link:
+		return w.link(termClean(string(n.Destination)), w.inlines(n))
+	case *ast.AutoLink:
+		url := termClean(string(n.URL(w.source)))
+		if n.AutoLinkType == ast.AutoLinkEmail && !strings.HasPrefix(strings.ToLower(url), "mailto:") {
+			url = "mailto:" + url
+		}
+		return w.hyperlink(url, termClean(string(n.Label(w.source))))
+	case *wikiLink:
+		return w.link(termClean(n.href), w.inlines(n))
+	case *ast.Image:
+		dest := termClean(string(n.Destination))
+		alt := termClean(plainText(n, w.source))
+		if alt == "" {
+			alt = dest
+		}
+		return w.hyperlink(dest, "[image: "+alt+"]")
+	case *east.TaskCheckBox:
+		if n.IsChecked {
+			return "☑ "
+		}
+		return "☐ "
+	case *east.FootnoteLink:
+		fn, ok := w.footnotes[n.Index]
+		if !ok {
+			return ""
+		}
+		num, ok := w.noteNums[n.Index]
+		if !ok {
+			w.notes = append(w.notes, w.blocks(fn, w.width-5, true))
+			num = len(w.notes)
+			w.noteNums[n.Index] = num
+		}
+		return w.style(termDim, "["+strconv.Itoa(num)+"]")
+	case *ast.RawHTML, *east.FootnoteBacklink, *imageAttrs:
+		return ""
+	}
+	return w.inlines(n)
+}
+
+// link returns a hyperlink; w/o support for them, the URL follows the text unless they are the same
+func (w *termWriter) link(dest, label string) string {
+	if dest == "" || strings.HasPrefix(dest, "#") {
+		return label
+	}
+	if w.color || termEscapeRe.ReplaceAllString(label, "") == dest {
+		return w.hyperlink(dest, label)
+	}
+	return label + " <" + dest + ">"
+}
+
+// termTextWidth returns the number of columns s takes on screen
+func termTextWidth(s string) int {
+	n := 0
+	for _, r := range termEscapeRe.ReplaceAllString(s, "") {
+		n += termRuneWidth(r)
+	}
+	return n
+}
+
+// termRuneWidth returns the number of columns of r: East Asian wide characters and emoji take 2
+func termRuneWidth(r rune) int {
+	switch {
+	case r < 0x1100:
+		return 1
+	case r <= 0x115f, r >= 0x2e80 && r <= 0xa4cf && r != 0x303f, r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff, r >= 0xfe30 && r <= 0xfe4f, r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6, r >= 0x1f300 && r <= 0x1f64f, r >= 0x1f900 && r <= 0x1f9ff,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
+
+// padTerm pads s w/ spaces to width, per alignment
+func padTerm(s string, width int, a east.Alignment) string {
+	gap := max(width-termTextWidth(s), 0)
+	switch a {
+	case east.AlignRight:
+		return strings.Repeat(" ", gap) + s
+	case east.AlignCenter:
+		return strings.Repeat(" ", gap/2) + s + strings.Repeat(" ", gap-gap/2)
+	}
+	return s + strings.Repeat(" ", gap)
+}
+
+// prefixTermLines prefixes the first line w/ first and others w/ rest; blank lines get no trailing
+// spaces
+func prefixTermLines(lines []string, first, rest string) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		p := rest
+		if i == 0 {
+			p = first
+		}
+		if l == "" {
+			p = strings.TrimRight(p, " ")
+		}
+		out[i] = p + l
+	}
+	return out
+}
+
+// termWrap breaks styled text into lines fitting in width at spaces; newlines break lines as well.
+// Styles and hyperlinks spanning a break get closed at the end of the line and reopened on the next,
+// so that lines can be prefixed e.g. by quote bars.
+func termWrap(s string, width int) []string {
+	var lines []string
+	// escape sequences in effect: SGR ones since the last reset, and the open hyperlink if any
+	var sgr []string
+	var link string
+	for _, para := range strings.Split(s, "\n") {
+		var line strings.Builder
+		lineWidth := 0
+		reopen := func() {
+			line.WriteString(strings.Join(sgr, ""))
+			if link != "" {
+				line.WriteString(link)
+			}
+		}
+		closeLine := func() {
+			if len(sgr) > 0 {
+				line.WriteString("\x1b[0m")
+			}
+			if link != "" {
+				line.WriteString("\x1b]8;;\x1b\\")
+			}
+			lines = append(lines, line.String())
+			line.Reset()
+			lineWidth = 0
+		}
+		reopen()
+		for i, word := range strings.Split(para, " ") {
+			ww := termTextWidth(word)
+			if lineWidth > 0 && lineWidth+1+ww > width {
+				closeLine()
+				reopen()
+			} else if i > 0 {
+				line.WriteString(" ")
+				lineWidth++
+			}
+			line.WriteString(word)
+			lineWidth += ww
+			for _, esc := range termEscapeRe.FindAllString(word, -1) {
+				switch {
+				case esc == "\x1b[0m":
+					sgr = nil
+				case strings.HasPrefix(esc, "\x1b]8;;"):
+					link = esc
+					if esc == "\x1b]8;;\x1b\\" {
+						link = ""
+					}
+				case termOffRe.MatchString(esc):
+					// styles nest, so an off sequence ends the latest style
+					if len(sgr) > 0 {
+						sgr = sgr[:len(sgr)-1]
+					}
+				default:
+					sgr = append(sgr, esc)
+				}
+			}
+		}
+		closeLine()
+	}
+	// trailing spaces of broken lines go
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " ")
+	}
+	return lines
+}
+
+// termLexer tells tokens of a programming language apart, enough to highlight code
+type termLexer struct {
+	keywords     map[string]bool
+	lineComments []string
+	// opening and closing delimiters of block comments, if any
+	blockComment [2]string
+	quotes       string
+	ignoreCase   bool
+}
+
+func newTermLexer(keywords string, lineComments []string, blockComment [2]string, quotes string, ignoreCase bool) *termLexer {
+	l := &termLexer{keywords: map[string]bool{}, lineComments: lineComments, blockComment: blockComment, quotes: quotes, ignoreCase: ignoreCase}
+	for _, k := range strings.Fields(keywords) {
+		l.keywords[k] = true
+	}
+	return l
+}
+
+var (
+	cLikeComment = [2]string{"/*", "*/"}
+	termLexers   = map[string]*termLexer{
+		"go": newTermLexer("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var true false nil iota",
+			[]string{"//"}, cLikeComment, "\"'`", false),
+		"python": newTermLexer("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield True False None",
+			[]string{"#"}, [2]string{}, "\"'", false),
+		"js": newTermLexer("async await break case catch class const continue debugger default delete do else enum export extends finally for function if implements import in instanceof interface let new return super switch this throw try type typeof var void while with yield true false null undefined",
+			[]string{"//"}, cLikeComment, "\"'`", false),
+		"sh": newTermLexer("if then else elif fi case esac for while until do done in function return local export",
+			[]string{"#"}, [2]string{}, "\"'", false),
+		"rust": newTermLexer("as async await break const continue crate dyn else enum extern false fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while",
+			[]string{"//"}, cLikeComment, "\"", false),
+		"java": newTermLexer("abstract boolean break byte case catch char class continue default do double else enum extends final finally float for if implements import instanceof int interface long new package private protected public return short static super switch this throw throws try void while true false null",
+			[]string{"//"}, cLikeComment, "\"'", false),
+		"c": newTermLexer("auto bool break case char class const continue default define delete do double else enum extern false float for goto if include int long namespace new nullptr private protected public register return short signed sizeof static struct switch template this true typedef typename union unsigned void volatile while",
+			[]string{"//"}, cLikeComment, "\"'", false),
+		"ruby": newTermLexer("alias and begin break case class def defined do else elsif end ensure false for if in module next nil not or redo rescue retry return self super then true undef unless until when while yield",
+			[]string{"#"}, [2]string{}, "\"'", false),
+		"sql": newTermLexer("select from where insert into values update set delete create table drop alter and or not null is in join left right inner outer on group by order having limit offset as distinct union all case when then else end primary key",
+			[]string{"--"}, cLikeComment, "'\"", true),
+		"data": newTermLexer("true false null", []string{"#"}, [2]string{}, "\"'", false),
+	}
+	// languages of fenced code blocks by the names docs use
+	termLexerAliases = map[string]string{
+		"go": "go", "golang": "go", "py": "python", "python": "python", "js": "js", "javascript": "js",
+		"jsx": "js", "ts": "js", "typescript": "js", "tsx": "js", "sh": "sh", "bash": "sh", "shell": "sh",
+		"zsh": "sh", "rs": "rust", "rust": "rust", "java": "java", "kotlin": "java", "c": "c", "h": "c",
+		"cpp": "c", "c++": "c", "hpp": "c", "cs": "java", "csharp": "java", "rb": "ruby", "ruby": "ruby",
+		"sql": "sql", "json": "data", "yaml": "data", "yml": "data", "toml": "data",
+	}
+)
+
+// highlight colors comments, strings, numbers and keywords of code in given language; code in other
+// languages is left as is
+func (w *termWriter) highlight(code, lang string) string {
+	lx, ok := termLexers[termLexerAliases[strings.ToLower(lang)]]
+	if !ok {
+		return code
+	}
+	var b strings.Builder
+	for i := 0; i < len(code); {
+		rest := code[i:]
+		tok, st := "", termStyle{}
+		switch {
+		case lx.blockComment[0] != "" && strings.HasPrefix(rest, lx.blockComment[0]):
+			end := strings.Index(rest[len(lx.blockComment[0]):], lx.blockComment[1])
+			if end < 0 {
+				tok = rest
+			} else {
+				tok = rest[:len(lx.blockComment[0])+end+len(lx.blockComment[1])]
+			}
+			st = termComment
+		case termHasAnyPrefix(rest, lx.lineComments):
+			tok, _, _ = strings.Cut(rest, "\n")
+			st = termComment
+		case strings.IndexByte(lx.quotes, rest[0]) >= 0:
+			q := rest[0]
+			j := 1
+			for j < len(rest) && rest[j] != q && (rest[j] != '\n' || q == '`') {
+				if rest[j] == '\\' && q != '`' {
+					j++
+				}
+				j++
+			}
+			tok = rest[:min(j+1, len(rest))]
+			st = termString
+		case rest[0] >= '0' && rest[0] <= '9' && (i == 0 || !termIsIdent(code[i-1])):
+			j := 1
+			for j < len(rest) && (termIsIdent(rest[j]) || rest[j] == '.') {
+				j++
+			}
+			tok, st = rest[:j], termNumber
+		case termIsIdent(rest[0]):
+			j := 1
+			for j < len(rest) && termIsIdent(rest[j]) {
+				j++
+			}
+			tok = rest[:j]
+			word := tok
+			if lx.ignoreCase {
+				word = strings.ToLower(word)
+			}
+			if lx.keywords[word] {
+				st = termKeyword
+			}
+		default:
+			_, size := utf8.DecodeRuneInString(rest)
+			tok = rest[:size]
+		}
+		if st.on != "" {
+			b.WriteString(w.style(st, tok))
+		} else {
+			b.WriteString(tok)
+		}
+		i += len(tok)
+	}
+	return b.String()
+}
+
+func termIsIdent(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func termHasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTermClean(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain\ttab\nnewline", "plain\ttab\nnewline"},
+		{"\x1b[31mred\x1b[0m", "[31mred[0m"},
+		{"bell\a del\x7f cr\r", "bell del cr"},
+		{"c1 \u009b31m csi", "c1 31m csi"},
+		{"ünïcödé …", "ünïcödé …"},
+	}
+	for _, tt := range tests {
+		if got := termClean(tt.in); got != tt.want {
+			t.Errorf("termClean(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRenderTerm(t *testing.T) {
+	tests := []struct {
+		name, md, profile, want string
+	}{
+		{
+			name:    "control characters",
+			md:      "Esc \x1b[2J &#27;]8;;x and `\a`\n\n```\ncode \x1b[41m\n```\n",
+			profile: "gfm-doc",
+			want:    "Esc [2J ]8;;x and\n\n    code [41m\n",
+		},
+		{
+			name:    "soft breaks reflow",
+			md:      "one\ntwo\n",
+			profile: "gfm-doc",
+			want:    "one two\n",
+		},
+		{
+			name:    "soft breaks w/ hardwraps",
+			md:      "one\ntwo\n",
+			profile: "gfm-comment",
+			want:    "one\ntwo\n",
+		},
+	}
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("COLUMNS", "40")
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "doc.md")
+			if err := os.WriteFile(path, []byte(tt.md), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			doc, err := loadDocument(path, markdownOptions{profile: tt.profile}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			if err := renderTerm(&out, doc.md, doc); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import "os"
+
+// terminalWidth is unknown w/o the TIOCGWINSZ ioctl; $COLUMNS or the default width is used instead
+func terminalWidth(f *os.File) (int, bool) {
+	return 0, false
+}
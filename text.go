@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// width of plain text output, as usual for emails and commit messages
+const textWidth = 72
+
+// underlines of headings by level; deeper levels use the last
+var textUnderlines = []string{"=", "-", "~"}
+
+// textBox is the box drawing of plain text tables, in ASCII
+var textBox = tableBox{
+	top:    [4]string{"+", "-", "+", "+"},
+	sep:    [4]string{"+", "=", "+", "+"},
+	bottom: [4]string{"+", "-", "+", "+"},
+	bar:    "|",
+}
+
+// renderText renders the doc as plain text wrapped at 72 columns, e.g. for emails and commit messages.
+// Links and footnotes are numbered like `[1]` and listed at the end.
+func renderText(w io.Writer, md goldmark.Markdown, doc *document) error {
+	root := md.Parser().Parse(text.NewReader(doc.source))
+	xw := &textWriter{source: doc.source, footnotes: map[int]*east.Footnote{}, refNums: map[string]int{}}
+	ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if fn, ok := n.(*east.Footnote); ok && entering {
+			xw.footnotes[fn.Index] = fn
+		}
+		return ast.WalkContinue, nil
+	})
+	lines := xw.blocks(root, textWidth, false)
+	if len(xw.refs) > 0 {
+		lines = append(lines, "")
+		for i, ref := range xw.refs {
+			marker := "[" + strconv.Itoa(i+1) + "] "
+			lines = append(lines, prefixTermLines(ref, marker, strings.Repeat(" ", len(marker)))...)
+		}
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// textWriter lays out a goldmark AST as lines of plain text
+type textWriter struct {
+	source []byte
+	// footnote definitions by index
+	footnotes map[int]*east.Footnote
+	// link URLs and footnotes referenced so far in order, laid out, and their numbers by URL or
+	// footnote index
+	refs    [][]string
+	refNums map[string]int
+}
+
+// blocks lays out the children of parent in width, w/ blank lines between them unless tight
+func (w *textWriter) blocks(parent ast.Node, width int, tight bool) []string {
+	var lines []string
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		b := w.block(c, width)
+		if b == nil {
+			continue
+		}
+		if lines != nil && !tight {
+			lines = append(lines, "")
+		}
+		lines = append(lines, b...)
+	}
+	return lines
+}
+
+func (w *textWriter) block(n ast.Node, width int) []string {
+	switch n := n.(type) {
+	case *ast.Heading:
+		lines := termWrap(w.inlines(n), width)
+		longest := 0
+		for _, l := range lines {
+			longest = max(longest, termTextWidth(l))
+		}
+		underline := textUnderlines[min(n.Level, len(textUnderlines))-1]
+		return append(lines, strings.Repeat(underline, longest))
+	case *ast.Paragraph, *ast.TextBlock:
+		return termWrap(w.inlines(n), width)
+	case *ast.ThematicBreak:
+		return []string{"* * *"}
+	case *ast.CodeBlock:
+		return prefixTermLines(w.code(n), "    ", "    ")
+	case *ast.FencedCodeBlock:
+		lines := prefixTermLines(w.code(n), "    ", "    ")
+		if n.Info != nil {
+			if fi := parseFenceInfo(string(n.Info.Segment.Value(w.source))); fi.title != "" {
+				lines = append([]string{fi.title + ":", ""}, lines...)
+			}
+		}
+		return lines
+	case *mathBlock:
+		return prefixTermLines(strings.Split(strings.TrimRight(mathText(n, w.source), "\n"), "\n"), "    ", "    ")
+	case *ast.Blockquote:
+		return prefixTermLines(w.blocks(n, width-2, false), "> ", "> ")
+	case *ast.List:
+		return w.list(n, width)
+	case *east.DefinitionList:
+		var lines []string
+		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+			if _, ok := c.(*east.DefinitionTerm); ok {
+				if lines != nil {
+					lines = append(lines, "")
+				}
+				lines = append(lines, termWrap(w.inlines(c), width)...)
+				continue
+			}
+			lines = append(lines, prefixTermLines(w.blocks(c, width-4, true), "    ", "    ")...)
+		}
+		return lines
+	case *east.Table:
+		var rows [][]string
+		for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+			var cells []string
+			for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+				cells = append(cells, strings.ReplaceAll(w.inlines(cell), "\n", " "))
+			}
+			rows = append(rows, cells)
+		}
+		return w.table(rows, n.Alignments, true, width)
+	case *csvTable:
+		lines := w.table(n.rows, nil, n.header, width)
+		if n.omitted > 0 {
+			more := fmt.Sprintf("... %d more rows", n.omitted)
+			if n.omitted == 1 {
+				more = "... 1 more row"
+			}
+			lines = append(lines, more)
+		}
+		return lines
+	case *containerBlock:
+		title := n.title
+		if title == "" {
+			title = strings.ToUpper(n.name[:1]) + n.name[1:]
+		}
+		lines := termWrap(title+":", width)
+		return append(lines, prefixTermLines(w.blocks(n, width-4, false), "    ", "    ")...)
+	case *figureBlock:
+		lines := termWrap(w.inlines(n), width)
+		if len(n.caption) > 0 {
+			lines = append(lines, termWrap(string(n.caption), width)...)
+		}
+		return lines
+	case *ast.HTMLBlock, *east.FootnoteList:
+		// raw html is not shown, as by default in html output; footnotes go to the end
+		return nil
+	}
+	return w.blocks(n, width, false)
+}
+
+func (w *textWriter) list(n *ast.List, width int) []string {
+	num := n.Start
+	var lines []string
+	for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+		marker := "- "
+		if n.IsOrdered() {
+			marker = strconv.Itoa(num) + ". "
+			num++
+		}
+		body := w.blocks(item, width-len(marker), n.IsTight)
+		if lines != nil && !n.IsTight {
+			lines = append(lines, "")
+		}
+		if len(body) == 0 {
+			body = []string{""}
+		}
+		lines = append(lines, prefixTermLines(body, marker, strings.Repeat(" ", len(marker)))...)
+	}
+	return lines
+}
+
+func (w *textWriter) table(rows [][]string, aligns []east.Alignment, header bool, width int) []string {
+	plain := func(s string) string { return s }
+	return boxTable(rows, aligns, header, width, textBox, plain, plain)
+}
+
+// code returns the lines of a code block, w/ tabs expanded so that they keep the indentation
+func (w *textWriter) code(n ast.Node) []string {
+	var b strings.Builder
+	for i := 0; i < n.Lines().Len(); i++ {
+		seg := n.Lines().At(i)
+		b.Write(seg.Value(w.source))
+	}
+	code := strings.ReplaceAll(strings.TrimRight(b.String(), "\n"), "\t", "    ")
+	return strings.Split(code, "\n")
+}
+
+// inlines returns the text of the inline children of parent; soft line breaks become spaces to
+// reflow and hard ones newlines
+func (w *textWriter) inlines(parent ast.Node) string {
+	var b strings.Builder
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		b.WriteString(w.inline(c))
+	}
+	return b.String()
+}
+
+func (w *textWriter) inline(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Text:
+		s := leafText(n, w.source)
+		if n.HardLineBreak() {
+			s += "\n"
+		} else if n.SoftLineBreak() {
+			s += " "
+		}
+		return s
+	case *ast.String:
+		return leafText(n, w.source)
+	case *ast.CodeSpan:
+		return "`" + plainText(n, w.source) + "`"
+	case *mathInline:
+		delim := "$"
+		if n.display {
+			delim = "$$"
+		}
+		return delim + mathText(n, w.source) + delim
+	case *ast.Emphasis:
+		if n.Level >= 2 {
+			return "*" + w.inlines(n) + "*"
+		}
+		return "_" + w.inlines(n) + "_"
+	case *east.Strikethrough:
+		return "~~" + w.inlines(n) + "~~"
+	case *inlineTag:
+		switch n.syntax.tag {
+		case "sub":
+			return "_" + w.inlines(n)
+		case "sup":
+			return "^" + w.inlines(n)
+		}
+		return w.inlines(n)
+	case *ast.Link:
+		return w.link(string(n.Destination), w.inlines(n))
+	case *ast.AutoLink:
+		return string(n.Label(w.source))
+	case *wikiLink:
+		return w.link(n.href, w.inlines(n))
+	case *ast.Image:
+		alt := plainText(n, w.source)
+		if alt == "" {
+			return w.link(string(n.Destination), "[image]")
+		}
+		return w.link(string(n.Destination), "[image: "+alt+"]")
+	case *east.TaskCheckBox:
+		if n.IsChecked {
+			return "[x] "
+		}
+		return "[ ] "
+	case *east.FootnoteLink:
+		fn, ok := w.footnotes[n.Index]
+		if !ok {
+			return ""
+		}
+		return "[" + strconv.Itoa(w.ref("^"+strconv.Itoa(n.Index), func() []string {
+			return w.blocks(fn, textWidth-5, true)
+		})) + "]"
+	case *ast.RawHTML, *east.FootnoteBacklink, *imageAttrs:
+		return ""
+	}
+	return w.inlines(n)
+}
+
+// link returns the label followed by the number of the URL in the list at the end; links within the
+// doc, and links w/ their URL as label, need no number
+func (w *textWriter) link(dest, label string) string {
+	if dest == "" || strings.HasPrefix(dest, "#") || label == dest {
+		return label
+	}
+	return label + " [" + strconv.Itoa(w.ref(dest, func() []string { return []string{dest} })) + "]"
+}
+
+// ref returns the number of the reference by key, listing it w/ its lines at the end the 1st time
+func (w *textWriter) ref(key string, lines func() []string) int {
+	if num, ok := w.refNums[key]; ok {
+		return num
+	}
+	w.refs = append(w.refs, nil)
+	num := len(w.refs)
+	w.refNums[key] = num
+	// footnotes may reference more, after this one
+	w.refs[num-1] = lines()
+	return num
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var kindWikiLink = ast.NewNodeKind("WikiLink")
+
+// wikiLink is a `[[Page Name#Section|label]]` link; its children hold the label
+type wikiLink struct {
+	ast.BaseInline
+	// target and anchor as written
+	target, anchor string
+	// link to the resolved page; empty if the page cannot be found
+	href string
+	// source between the brackets
+	raw string
+}
+
+func (n *wikiLink) Kind() ast.NodeKind {
+	return kindWikiLink
+}
+
+func (n *wikiLink) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": n.target, "Href": n.href}, nil)
+}
+
+// wikiLinkSyntax is the goldmark extension of wiki links, resolving targets by title or file name
+// among the Markdown files under a root directory
+type wikiLinkSyntax struct {
+	root string
+	// directory of the doc being converted, which links are relative to
+	docDir string
+	// extension of link targets in place of the Markdown one; empty keeps it
+	ext string
+
+	indexOnce sync.Once
+	// normalized file names and titles -> file paths
+	index map[string]string
+
+	mu sync.Mutex
+	// unresolved targets warned about already; docs get parsed more than once e.g. to expand includes
+	warned map[string]bool
+}
+
+func newWikiLinkSyntax(cfg *config, docDir string) *wikiLinkSyntax {
+	root := docDir
+	if cfg.Wiki.Root != "" {
+		root = filepath.Join(cfg.dir, cfg.Wiki.Root)
+	}
+	return &wikiLinkSyntax{root: root, docDir: docDir, ext: cfg.Wiki.Ext, warned: map[string]bool{}}
+}
+
+func (s *wikiLinkSyntax) Extend(m goldmark.Markdown) {
+	// ahead of the link parser which would take `[[` for brackets of a regular link
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(s, 199)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(s, 500)))
+}
+
+func (s *wikiLinkSyntax) Trigger() []byte {
+	return []byte{'['}
+}
+
+func (s *wikiLinkSyntax) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	if !bytes.HasPrefix(line, []byte("[[")) {
+		return nil
+	}
+	end := bytes.Index(line[2:], []byte("]]"))
+	if end < 0 {
+		return nil
+	}
+	inner := line[2 : 2+end]
+	if len(bytes.TrimSpace(inner)) == 0 || bytes.ContainsAny(inner, "[]") {
+		return nil
+	}
+	target, labelAt, labelLen := inner, 2, len(inner)
+	if i := bytes.IndexByte(inner, '|'); i >= 0 {
+		target, labelAt, labelLen = inner[:i], 2+i+1, len(inner)-i-1
+	}
+	t, anchor, _ := strings.Cut(strings.TrimSpace(string(target)), "#")
+	n := &wikiLink{target: strings.TrimSpace(t), anchor: strings.TrimSpace(anchor), raw: string(inner)}
+	n.href = s.resolve(n.target, n.anchor)
+	label := text.NewSegment(segment.Start+labelAt, segment.Start+labelAt+labelLen)
+	label = label.TrimLeftSpace(block.Source())
+	n.AppendChild(n, ast.NewTextSegment(label.TrimRightSpace(block.Source())))
+	block.Advance(2 + end + 2)
+	return n
+}
+
+// resolve returns the href of given page, or an empty string if no page matches
+func (s *wikiLinkSyntax) resolve(target, anchor string) string {
+	if target == "" {
+		// `[[#Section]]` links within the doc itself
+		return "#" + headingSlug(anchor)
+	}
+	s.indexOnce.Do(s.buildIndex)
+	p, ok := s.index[wikiKey(target)]
+	if !ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.warned[target] {
+			s.warned[target] = true
+			fmt.Fprintf(os.Stderr, "warning: unresolved wiki link [[%s]] in %s\n", target, s.root)
+		}
+		return ""
+	}
+	rel, err := filepath.Rel(s.docDir, p)
+	if err != nil {
+		rel = p
+	}
+	if s.ext != "" {
+		rel = strings.TrimSuffix(rel, filepath.Ext(rel)) + s.ext
+	}
+	href := (&url.URL{Path: filepath.ToSlash(rel)}).String()
+	if anchor != "" {
+		href += "#" + headingSlug(anchor)
+	}
+	return href
+}
+
+func (s *wikiLinkSyntax) buildIndex() {
+	s.index = map[string]string{}
+	// titles go in after file names, so that a file name wins over another page's title
+	titles := map[string]string{}
+	filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != s.root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(p))
+		if ext != ".md" && ext != ".markdown" {
+			return nil
+		}
+		if key := wikiKey(strings.TrimSuffix(d.Name(), filepath.Ext(p))); s.index[key] == "" {
+			s.index[key] = p
+		}
+		if title := docTitle(p); title != "" {
+			if key := wikiKey(title); titles[key] == "" {
+				titles[key] = p
+			}
+		}
+		return nil
+	})
+	for key, p := range titles {
+		if s.index[key] == "" {
+			s.index[key] = p
+		}
+	}
+}
+
+// docTitle returns the title of a Markdown file: the title of its front matter, otherwise its
+// first level 1 heading
+func docTitle(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	head := make([]byte, 4096)
+	n, _ := f.Read(head)
+	meta, body := splitFrontMatter(head[:n])
+	if meta["title"] != "" {
+		return meta["title"]
+	}
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); strings.HasPrefix(line, "# ") {
+			return strings.TrimSpace(strings.TrimRight(line[2:], "#"))
+		}
+	}
+	return ""
+}
+
+var wikiKeySepRe = regexp.MustCompile(`[\s_-]+`)
+
+// wikiKey normalizes page names so that `Page Name`, `page-name` and `page_name` match
+func wikiKey(name string) string {
+	return wikiKeySepRe.ReplaceAllString(strings.ToLower(strings.TrimSpace(name)), " ")
+}
+
+var slugStripRe = regexp.MustCompile(`[^\p{L}\p{N}\s_-]+`)
+
+// headingSlug returns the GitHub style anchor of a heading
+func headingSlug(heading string) string {
+	s := slugStripRe.ReplaceAllString(strings.ToLower(strings.TrimSpace(heading)), "")
+	return strings.ReplaceAll(s, " ", "-")
+}
+
+func (s *wikiLinkSyntax) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindWikiLink, s.render)
+}
+
+func (s *wikiLinkSyntax) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*wikiLink)
+	switch {
+	case !entering:
+		_, _ = w.WriteString("</a>")
+	case n.href == "":
+		_, _ = w.WriteString(`<a class="absent" title="` + html.EscapeString(n.target) + ` does not exist">`)
+	default:
+		_, _ = w.WriteString(`<a href="` + html.EscapeString(n.href) + `">`)
+	}
+	return ast.WalkContinue, nil
+}
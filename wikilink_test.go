@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStderr returns what f writes to stderr
+func captureStderr(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+	out := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- b
+	}()
+	f()
+	w.Close()
+	return string(<-out)
+}
+
+func TestWikiLinks(t *testing.T) {
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"guide/Getting-Started.md": "# Start here\n",
+		"notes.md":                 "---\ntitle: Release Notes\n---\nbody\n",
+		"faq.md":                   "# Getting Started\n",
+		"docs/index.md":            "",
+		".hidden/secret.md":        "",
+	} {
+		p := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name, md, want, warning string
+		ext                     string
+	}{
+		{
+			name: "file name",
+			md:   "[[getting started]]",
+			want: `<p><a href="../guide/Getting-Started.md">getting started</a></p>`,
+		},
+		{
+			name: "title w/ label",
+			md:   "[[Release_Notes | what's new]]",
+			want: `<p><a href="../notes.md">what's new</a></p>`,
+		},
+		{
+			name: "heading title",
+			md:   "[[Start Here#First Steps!]]",
+			want: `<p><a href="../guide/Getting-Started.md#first-steps">Start Here#First Steps!</a></p>`,
+		},
+		{
+			name: "within the doc",
+			md:   "[[#Some Section|above]]",
+			want: `<p><a href="#some-section">above</a></p>`,
+		},
+		{
+			name: "ext of config",
+			md:   "[[notes]]",
+			ext:  ".html",
+			want: `<p><a href="../notes.html">notes</a></p>`,
+		},
+		{
+			name:    "absent",
+			md:      "[[Missing Page]] and [[missing page]] and [[Missing Page]]",
+			want:    `<p><a class="absent" title="Missing Page does not exist">Missing Page</a> and <a class="absent" title="missing page does not exist">missing page</a> and <a class="absent" title="Missing Page does not exist">Missing Page</a></p>`,
+			warning: "warning: unresolved wiki link [[Missing Page]] in " + root + "\nwarning: unresolved wiki link [[missing page]] in " + root + "\n",
+		},
+		{
+			name:    "hidden directory",
+			md:      "[[secret]]",
+			want:    `<p><a class="absent" title="secret does not exist">secret</a></p>`,
+			warning: "warning: unresolved wiki link [[secret]] in " + root + "\n",
+		},
+		{
+			name: "not a wiki link",
+			md:   "[[a [b] c]] [[ ]]",
+			want: "<p>[[a [b] c]] [[ ]]</p>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config{dir: root}
+			cfg.Wiki.Root = "."
+			cfg.Wiki.Ext = tt.ext
+			md, err := markdownFor(markdownOptions{profile: "gfm-doc", ext: "+wikilink", docDir: filepath.Join(root, "docs")}, nil, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			warning := captureStderr(t, func() {
+				err = md.Convert([]byte(tt.md), &out)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(out.String()); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if warning != tt.warning {
+				t.Errorf("got warning %q, want %q", warning, tt.warning)
+			}
+		})
+	}
+}